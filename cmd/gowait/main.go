@@ -104,12 +104,23 @@ func main() {
 
 	// go wait!
 	log.Fields(map[string]interface{}{
-		"url":        urlStr,
-		"maxRetries": cfg.RetryLimit,
-		"retryDelay": cfg.RetryDelay.String(),
+		"url":              urlStr,
+		"maxRetries":       cfg.RetryLimit,
+		"retryDelay":       cfg.RetryDelay.String(),
+		"mode":             cfg.Mode,
+		"successThreshold": cfg.SuccessThreshold,
+		"stableFor":        cfg.StableFor.String(),
 	}).
 		Infof("Starting to wait")
-	err := waiter.Wait(cfg.Url, cfg.RetryDelay, cfg.RetryLimit)
+	var err error
+	switch {
+	case cfg.Mode == config.ModeDown:
+		err = waiter.WaitDown(cfg.Url, cfg.RetryDelay, cfg.RetryLimit, cfg.SuccessThreshold, cfg.StableFor)
+	case cfg.SuccessThreshold > 1 || cfg.StableFor > 0:
+		err = waiter.WaitStable(cfg.Url, cfg.RetryDelay, cfg.RetryLimit, cfg.SuccessThreshold, cfg.StableFor)
+	default:
+		err = waiter.Wait(cfg.Url, cfg.RetryDelay, cfg.RetryLimit)
+	}
 	if err != nil {
 		log.Field("url", urlStr).
 			Err(err).
@@ -2,6 +2,8 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/url"
 	"os"
 	"strconv"
@@ -13,73 +15,105 @@ import (
 )
 
 const (
-	RetryLimitDefault   = 5
-	RetryDelayDefault   = 10 * time.Second
-	ConfSourceDefault   = ConfSourceEnv
-	SecretSourceDefault = SecretSourceEnv
-	LogFormatDefault    = logger.LogFormatText
-	LogLevelDefault     = logger.LogLevelInfo
+	RetryLimitDefault       = 5
+	RetryDelayDefault       = 10 * time.Second
+	ConfSourceDefault       = ConfSourceEnv
+	SecretSourceDefault     = SecretSourceEnv
+	LogFormatDefault        = logger.LogFormatText
+	LogLevelDefault         = logger.LogLevelInfo
+	ModeDefault             = ModeUp
+	SuccessThresholdDefault = 1
+	StableForDefault        = time.Duration(0)
 
 	ConfSourceEnv  = "env"
 	ConfSourceYAML = "yaml"
 	ConfSourceJSON = "json"
 
-	KeyRetryDelay     = "retryDelay"
-	KeyRetryLimit     = "retryLimit"
-	KeyURL            = "url"
-	KeySecretSource   = "secretSource"
-	KeySecretFilename = "secretFilename"
-	KeyLogFormat      = "logFormat"
-	KeyLogLevel       = "logLevel"
+	KeyRetryDelay       = "retryDelay"
+	KeyRetryLimit       = "retryLimit"
+	KeyURL              = "url"
+	KeySecretSource     = "secretSource"
+	KeySecretFilename   = "secretFilename"
+	KeyLogFormat        = "logFormat"
+	KeyLogLevel         = "logLevel"
+	KeyMode             = "mode"
+	KeySuccessThreshold = "successThreshold"
+	KeyStableFor        = "stableFor"
 
-	EnvRetryDelay     = "GOWAIT_RETRY_DELAY"
-	EnvRetryLimit     = "GOWAIT_RETRY_LIMIT"
-	EnvURL            = "GOWAIT_URL"
-	EnvSecretSource   = "GOWAIT_SECRET_SOURCE"
-	EnvSecretFilename = "GOWAIT_SECRET_FILENAME"
-	EnvSecret         = "GOWAIT_SECRET"
-	EnvLogFormat      = "GOWAIT_LOG_FORMAT"
-	EnvLogLevel       = "GOWAIT_LOG_LEVEL"
+	EnvRetryDelay       = "GOWAIT_RETRY_DELAY"
+	EnvRetryLimit       = "GOWAIT_RETRY_LIMIT"
+	EnvURL              = "GOWAIT_URL"
+	EnvSecretSource     = "GOWAIT_SECRET_SOURCE"
+	EnvSecretFilename   = "GOWAIT_SECRET_FILENAME"
+	EnvSecret           = "GOWAIT_SECRET"
+	EnvLogFormat        = "GOWAIT_LOG_FORMAT"
+	EnvLogLevel         = "GOWAIT_LOG_LEVEL"
+	EnvMode             = "GOWAIT_MODE"
+	EnvSuccessThreshold = "GOWAIT_SUCCESS_THRESHOLD"
+	EnvStableFor        = "GOWAIT_STABLE_FOR"
 
 	SecretSourceEnv  = "env"
 	SecretSourceFile = "file"
+
+	// ModeUp waits for the service to be available
+	ModeUp = "up"
+	// ModeDown waits for the service to stop being available
+	ModeDown = "down"
+	// ModePresent is an alias of ModeUp
+	ModePresent = "present"
+	// ModeAbsent is an alias of ModeDown
+	ModeAbsent = "absent"
 )
 
 var (
 	EnvironmentVarMap = map[string]string{
-		EnvRetryDelay:     KeyRetryDelay,
-		EnvRetryLimit:     KeyRetryLimit,
-		EnvURL:            KeyURL,
-		EnvSecretSource:   KeySecretSource,
-		EnvSecretFilename: KeySecretFilename,
-		EnvLogFormat:      KeyLogFormat,
-		EnvLogLevel:       KeyLogLevel,
+		EnvRetryDelay:       KeyRetryDelay,
+		EnvRetryLimit:       KeyRetryLimit,
+		EnvURL:              KeyURL,
+		EnvSecretSource:     KeySecretSource,
+		EnvSecretFilename:   KeySecretFilename,
+		EnvLogFormat:        KeyLogFormat,
+		EnvLogLevel:         KeyLogLevel,
+		EnvMode:             KeyMode,
+		EnvSuccessThreshold: KeySuccessThreshold,
+		EnvStableFor:        KeyStableFor,
 	}
+
+	ErrInvalidMode             = errors.New("invalid mode")
+	ErrInvalidSuccessThreshold = errors.New("invalid successThreshold; must be a positive integer")
+	ErrInvalidStableFor        = errors.New("invalid stableFor; must be a non-negative duration")
 )
 
 // AppConfig represents the struct of application configuration info
 type AppConfig struct {
-	Url            url.URL       `yaml:"url" json:"url"`
-	ConfigSource   string        `yaml:"-" json:"-"`
-	ConfigFilename string        `yaml:"-" json:"-"`
-	Secret         string        `yaml:"-" json:"-"`
-	SecretSource   string        `yaml:"secretSource" json:"secretSource"`
-	SecretFilename string        `yaml:"secretFilename" json:"secretFilename"`
-	LogFormat      string        `yaml:"logFormat" json:"logFormat"`
-	LogLevel       string        `yaml:"logLevel" json:"logLevel"`
-	RetryDelay     time.Duration `yaml:"retryDelay" json:"retryDelay"`
-	RetryLimit     int           `yaml:"retryLimit" json:"retryLimit"`
+	Url              url.URL       `yaml:"url" json:"url"`
+	ConfigSource     string        `yaml:"-" json:"-"`
+	ConfigFilename   string        `yaml:"-" json:"-"`
+	Secret           string        `yaml:"-" json:"-"`
+	SecretSource     string        `yaml:"secretSource" json:"secretSource"`
+	SecretFilename   string        `yaml:"secretFilename" json:"secretFilename"`
+	LogFormat        string        `yaml:"logFormat" json:"logFormat"`
+	LogLevel         string        `yaml:"logLevel" json:"logLevel"`
+	Mode             string        `yaml:"mode" json:"mode"`
+	RetryDelay       time.Duration `yaml:"retryDelay" json:"retryDelay"`
+	RetryLimit       int           `yaml:"retryLimit" json:"retryLimit"`
+	SuccessThreshold int           `yaml:"successThreshold" json:"successThreshold"`
+	StableFor        time.Duration `yaml:"stableFor" json:"stableFor"`
 }
 
 // AppConfigFile represents the configuration struct in a flat file
 type AppConfigFile struct {
-	Url            string `yaml:"url" json:"url"`
-	RetryDelay     string `yaml:"retryDelay" json:"retryDelay"`
-	SecretSource   string `yaml:"secretSource" json:"secretSource"`
-	SecretFilename string `yaml:"secretFilename" json:"secretFilename"`
-	LogFormat      string `yaml:"logFormat" json:"logFormat"`
-	LogLevel       string `yaml:"logLevel" json:"logLevel"`
-	RetryLimit     int    `yaml:"retryLimit" json:"retryLimit"`
+	// SuccessThreshold is a pointer so that an explicit zero can be told apart from an omitted value
+	SuccessThreshold *int   `yaml:"successThreshold" json:"successThreshold"`
+	Url              string `yaml:"url" json:"url"`
+	RetryDelay       string `yaml:"retryDelay" json:"retryDelay"`
+	SecretSource     string `yaml:"secretSource" json:"secretSource"`
+	SecretFilename   string `yaml:"secretFilename" json:"secretFilename"`
+	LogFormat        string `yaml:"logFormat" json:"logFormat"`
+	LogLevel         string `yaml:"logLevel" json:"logLevel"`
+	Mode             string `yaml:"mode" json:"mode"`
+	StableFor        string `yaml:"stableFor" json:"stableFor"`
+	RetryLimit       int    `yaml:"retryLimit" json:"retryLimit"`
 }
 
 func ReadEnvironmentVariables(cm configmap.ConfigMap) {
@@ -150,6 +184,28 @@ func (ac *AppConfig) LoadFromConfigMap(cm configmap.ConfigMap) error {
 	if cm.GetString(KeyLogLevel) != "" {
 		ac.LogLevel = cm.GetString(KeyLogLevel)
 	}
+	// mode
+	mode, err := ParseMode(cm.GetString(KeyMode))
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse mode from config")
+		return err
+	}
+	ac.Mode = mode
+	// successThreshold
+	ac.SuccessThreshold, err = ParseSuccessThreshold(cm.GetString(KeySuccessThreshold))
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse successThreshold from config")
+		return err
+	}
+	// stableFor
+	ac.StableFor, err = ParseStableFor(cm.GetString(KeyStableFor))
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse stableFor from config")
+		return err
+	}
 	// done.
 	return nil
 }
@@ -181,9 +237,73 @@ func (ac *AppConfig) PopulateFromAppConfigFile(fileCfg *AppConfigFile) error {
 	ac.SecretFilename = fileCfg.SecretFilename
 	ac.LogFormat = fileCfg.LogFormat
 	ac.LogLevel = fileCfg.LogLevel
+	ac.Mode, err = ParseMode(fileCfg.Mode)
+	if err != nil {
+		log.Err(err).
+			Field("mode", fileCfg.Mode).
+			Error("error parsing Mode")
+		return err
+	}
+	rawThreshold := ""
+	if fileCfg.SuccessThreshold != nil {
+		rawThreshold = strconv.Itoa(*fileCfg.SuccessThreshold)
+	}
+	ac.SuccessThreshold, err = ParseSuccessThreshold(rawThreshold)
+	if err != nil {
+		log.Err(err).
+			Field("successThreshold", rawThreshold).
+			Error("error parsing SuccessThreshold")
+		return err
+	}
+	ac.StableFor, err = ParseStableFor(fileCfg.StableFor)
+	if err != nil {
+		log.Err(err).
+			Field("stableFor", fileCfg.StableFor).
+			Error("error parsing StableFor")
+		return err
+	}
 	return nil
 }
 
+// ParseMode returns the mode that a configured mode value stands for; an empty value is the default mode
+func ParseMode(rawMode string) (string, error) {
+	switch rawMode {
+	case "":
+		return ModeDefault, nil
+	case ModeUp, ModePresent:
+		return ModeUp, nil
+	case ModeDown, ModeAbsent:
+		return ModeDown, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrInvalidMode, rawMode)
+}
+
+// ParseSuccessThreshold returns the number of consecutive successful attempts that a configured value stands for; an
+// empty value is the default threshold
+func ParseSuccessThreshold(rawThreshold string) (int, error) {
+	if rawThreshold == "" {
+		return SuccessThresholdDefault, nil
+	}
+	threshold, err := strconv.Atoi(rawThreshold)
+	if err != nil || threshold < 1 {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidSuccessThreshold, rawThreshold)
+	}
+	return threshold, nil
+}
+
+// ParseStableFor returns the duration that a configured stableFor value stands for; an empty value is the default
+// duration
+func ParseStableFor(rawStableFor string) (time.Duration, error) {
+	if rawStableFor == "" {
+		return StableForDefault, nil
+	}
+	stableFor, err := time.ParseDuration(rawStableFor)
+	if err != nil || stableFor < 0 {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidStableFor, rawStableFor)
+	}
+	return stableFor, nil
+}
+
 func (ac *AppConfig) LoadFromYAML(fileName string) error {
 	log := logger.Function("LoadFromYAML")
 	log.Field("file", fileName).
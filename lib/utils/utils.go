@@ -2,6 +2,7 @@ package utils
 
 import (
 	"net/url"
+	"strings"
 
 	"github.com/neflyte/gowait/lib/logger"
 )
@@ -21,3 +22,15 @@ func SanitizedURLString(urlWithCreds url.URL) string {
 	}
 	return clone.String()
 }
+
+// SplitList returns the trimmed, non-empty items of a comma-separated list
+func SplitList(list string) []string {
+	items := make([]string, 0)
+	for _, tok := range strings.Split(list, ",") {
+		item := strings.TrimSpace(tok)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -48,6 +48,14 @@ declare PROGARGS=""
       export GOWAIT_SECRET=""
       export GOWAIT_LOG_FORMAT="text"
       ;;
+    "tcp-banner")
+      # reads the SMTP banner of the smtp test service and requires the connection to stay open
+      export GOWAIT_URL="tcp://localhost:1025/?expect=%5E220&holdOpen=2s"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="3"
+      export GOWAIT_SECRET=""
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
     "kafka")
       export GOWAIT_URL="kafka://localhost:9092/"
       export GOWAIT_RETRY_DELAY="3s"
@@ -55,6 +63,179 @@ declare PROGARGS=""
       export GOWAIT_SECRET=""
       export GOWAIT_LOG_FORMAT="text"
       ;;
+    "sqlserver")
+      export GOWAIT_URL="sqlserver://sa@localhost:1433?database=master&onlineDatabase=master"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="3"
+      export GOWAIT_SECRET="G0wait-Passw0rd"
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
+    "clickhouse")
+      export GOWAIT_URL="clickhouse://default@localhost:8123/"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="3"
+      export GOWAIT_SECRET=""
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
+    "cql")
+      export GOWAIT_URL="cql://localhost:9042/?keyspace=system"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="3"
+      export GOWAIT_SECRET=""
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
+    "elasticsearch")
+      export GOWAIT_URL="elasticsearch://localhost:9200/?status=green"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="3"
+      export GOWAIT_SECRET=""
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
+    "etcd")
+      export GOWAIT_URL="etcd://localhost:2379/"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="3"
+      export GOWAIT_SECRET=""
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
+    "zk")
+      export GOWAIT_URL="zk://localhost:2181/?modes=standalone"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="3"
+      export GOWAIT_SECRET=""
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
+    "consul")
+      export GOWAIT_URL="consul://localhost:8500/?service=consul"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="3"
+      export GOWAIT_SECRET=""
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
+    "vault")
+      export GOWAIT_URL="vault://localhost:8200/"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="3"
+      export GOWAIT_SECRET=""
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
+    "s3")
+      export GOWAIT_URL="s3://minio@artifacts/?endpoint=http://localhost:9000"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="3"
+      export GOWAIT_SECRET="minio123"
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
+    "s3-object")
+      # the setup service uploads an object whose key needs escaping
+      export GOWAIT_URL="s3://minio@artifacts/builds/a+b=1.txt?endpoint=http://localhost:9000"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="3"
+      export GOWAIT_SECRET="minio123"
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
+    "memcached")
+      export GOWAIT_URL="memcached://localhost:11211/"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="3"
+      export GOWAIT_SECRET=""
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
+    "mqtt")
+      export GOWAIT_URL="mqtt://localhost:1883/"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="3"
+      export GOWAIT_SECRET=""
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
+    "ldap")
+      export GOWAIT_URL="ldap://cn=admin,dc=example,dc=org@localhost:389/dc=example,dc=org"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="3"
+      export GOWAIT_SECRET="adminpassword"
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
+    "smtp")
+      export GOWAIT_URL="smtp://localhost:1025/"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="3"
+      export GOWAIT_SECRET=""
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
+    "dns")
+      export GOWAIT_URL="dns://localhost:1053/gowait.test?type=A&expect=10.0.0.5"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="3"
+      export GOWAIT_SECRET=""
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
+    "udp")
+      export GOWAIT_URL="udp://localhost:5005/?send=ping&expect=^ping"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="3"
+      export GOWAIT_SECRET=""
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
+    "unix")
+      export GOWAIT_URL="http+unix:///var/run/docker.sock?path=/_ping"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="3"
+      export GOWAIT_SECRET=""
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
+    "file")
+      export GOWAIT_URL="file:///etc?type=dir&nonEmpty=true"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="3"
+      export GOWAIT_SECRET=""
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
+    "exec")
+      export GOWAIT_URL="exec:uname?arg=-s&timeout=5s&match=Linux"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="3"
+      export GOWAIT_SECRET=""
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
+    "tls")
+      export GOWAIT_URL="tls://localhost:8443/?tlsCA=testdata/tls/certs/cert.pem&san=localhost&minValidity=24h&alpn=h2"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="3"
+      export GOWAIT_SECRET=""
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
+    "websocket")
+      export GOWAIT_URL="ws://localhost:8080/.ws?send=hello&expect=^hello$"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="3"
+      export GOWAIT_SECRET=""
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
+    "prometheus")
+      export GOWAIT_URL="prometheus://localhost:9090/?source=query&condition=up%7Bjob%3D%22prometheus%22%7D%20%3D%3D%201"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="3"
+      export GOWAIT_SECRET=""
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
+    "down")
+      # run once the http test service has been stopped
+      export GOWAIT_URL="http://localhost:8080/"
+      export GOWAIT_MODE="down"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="3"
+      export GOWAIT_SECRET=""
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
+    "stable")
+      export GOWAIT_URL="http://localhost:8080/"
+      export GOWAIT_SUCCESS_THRESHOLD="3"
+      export GOWAIT_STABLE_FOR="5s"
+      export GOWAIT_RETRY_DELAY="3s"
+      export GOWAIT_RETRY_LIMIT="6"
+      export GOWAIT_SECRET=""
+      export GOWAIT_LOG_FORMAT="text"
+      ;;
     *)
       echo "*  unknown test ${TESTOPT}; aborting"
       exit 1
@@ -62,9 +243,10 @@ declare PROGARGS=""
   esac
 }
 # display the parameters that were set
-echo -e "Test Parameters:\n\tURL: $GOWAIT_URL\n\tRetry Delay: $GOWAIT_RETRY_DELAY\n\tRetry Limit: $GOWAIT_RETRY_LIMIT\n\tSecret: $GOWAIT_SECRET\n\tLogFormat: $GOWAIT_LOG_FORMAT"
+echo -e "Test Parameters:\n\tURL: $GOWAIT_URL\n\tRetry Delay: $GOWAIT_RETRY_DELAY\n\tRetry Limit: $GOWAIT_RETRY_LIMIT\n\tSecret: $GOWAIT_SECRET\n\tLogFormat: $GOWAIT_LOG_FORMAT\n\tMode: $GOWAIT_MODE"
 # run the test
 bin/gowait ${PROGARGS}
 # unexport the test variables
-export -n GOWAIT_URL GOWAIT_RETRY_DELAY GOWAIT_RETRY_LIMIT GOWAIT_SECRET GOWAIT_LOG_FORMAT
+export -n GOWAIT_URL GOWAIT_RETRY_DELAY GOWAIT_RETRY_LIMIT GOWAIT_SECRET GOWAIT_LOG_FORMAT GOWAIT_MODE \
+  GOWAIT_SUCCESS_THRESHOLD GOWAIT_STABLE_FOR
 echo "done."
\ No newline at end of file
@@ -0,0 +1,299 @@
+package waiter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neflyte/gowait/config"
+	"github.com/neflyte/gowait/lib/logger"
+	"github.com/neflyte/gowait/lib/utils"
+)
+
+// url: clickhouse://user@host:8123/?secure=true&cluster=clustername
+
+const (
+	ClickHouseParamSecure  = "secure"
+	ClickHouseParamCluster = "cluster"
+
+	clickHouseHTTPPort  = "8123"
+	clickHouseHTTPSPort = "8443"
+
+	clickHousePingPath   = "/ping"
+	clickHousePingReply  = "Ok."
+	clickHousePingQuery  = "SELECT 1"
+	clickHouseUserHeader = "X-ClickHouse-User"
+	clickHouseKeyHeader  = "X-ClickHouse-Key"
+
+	// clickHouseReplicasQuery counts the replicas that system.clusters defines for a cluster
+	clickHouseReplicasQuery = "SELECT count() FROM system.clusters WHERE cluster = '%s'"
+	// clickHouseReachableQuery counts the replicas of a cluster that answer a query; it fails if any replica is
+	// unreachable
+	clickHouseReachableQuery = "SELECT count() FROM clusterAllReplicas('%s', system.one)"
+)
+
+var (
+	ErrClickHouseCluster = errors.New("not all cluster replicas are reachable")
+
+	// clickHouseClusterName restricts cluster names to characters that are safe to put into a query
+	clickHouseClusterName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+)
+
+type clickHouseWaiter struct {
+	ticker     *time.Ticker
+	client     *http.Client
+	baseURL    url.URL
+	user       *url.Userinfo
+	cluster    string
+	attempts   int
+	retryDelay time.Duration
+}
+
+var _ prober = (*clickHouseWaiter)(nil)
+
+func NewClickHouseWaiter() Waiter {
+	return &clickHouseWaiter{
+		client:     http.DefaultClient,
+		baseURL:    url.URL{},
+		user:       nil,
+		cluster:    "",
+		attempts:   0,
+		retryDelay: config.RetryDelayDefault,
+		ticker:     time.NewTicker(config.RetryDelayDefault),
+	}
+}
+
+func (cw *clickHouseWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
+	log := logger.Function("Wait").
+		Field("waiter", "ClickHouseWaiter")
+	err := cw.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
+	success := false
+	startTime := time.Now()
+	log.Field("retryDelay", retryDelay.String()).
+		Info("Using retry delay")
+	cw.ticker = time.NewTicker(retryDelay)
+	cw.retryDelay = retryDelay
+	urlStr := utils.SanitizedURLString(url)
+	cw.attempts = 0
+	for cw.attempts < retryLimit {
+		log.Field("url", urlStr).
+			Infof("[%d/%d] Connecting", cw.attempts+1, retryLimit)
+		err = cw.connectOnce()
+		cw.attempts++ // no matter what happens, we made an attempt
+		if err != nil {
+			if cw.attempts >= retryLimit {
+				log.Err(err).
+					Error("Connect error: retry limit reached; giving up")
+				break
+			}
+			log.Err(err).
+				Error("Connect error; delaying until next retry")
+			cw.delayOnce()
+			continue
+		}
+		// we're good
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    cw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Info("Successfully connected")
+		success = true
+		break
+	}
+	if !success {
+		errStr := fmt.Sprintf("Unable to connect to '%s' after %d attempts; elapsed time: %s", urlStr, cw.attempts, time.Since(startTime).String())
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    cw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Error("Unable to connect")
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// probe makes a single attempt without retrying or delaying
+func (cw *clickHouseWaiter) probe(url url.URL) error {
+	err := cw.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return cw.connectOnce()
+}
+
+// parseOptions builds the HTTP interface url and reads the gowait-specific query parameters from the url
+func (cw *clickHouseWaiter) parseOptions(chUrl url.URL) error {
+	query := chUrl.Query()
+	client, err := newHTTPClient(query)
+	if err != nil {
+		return err
+	}
+	cw.client = client
+	secure := false
+	rawSecure := query.Get(ClickHouseParamSecure)
+	if rawSecure != "" {
+		secure, err = strconv.ParseBool(rawSecure)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidOptions, ClickHouseParamSecure, err.Error())
+		}
+	}
+	cw.baseURL = url.URL{
+		Scheme: "http",
+		Host:   chUrl.Host,
+	}
+	port := clickHouseHTTPPort
+	if secure {
+		cw.baseURL.Scheme = "https"
+		port = clickHouseHTTPSPort
+	}
+	if chUrl.Port() == "" {
+		cw.baseURL.Host = net.JoinHostPort(chUrl.Hostname(), port)
+	}
+	cw.user = chUrl.User
+	cw.cluster = query.Get(ClickHouseParamCluster)
+	if cw.cluster != "" && !clickHouseClusterName.MatchString(cw.cluster) {
+		return fmt.Errorf("%w: invalid %s '%s'", ErrInvalidOptions, ClickHouseParamCluster, cw.cluster)
+	}
+	return nil
+}
+
+func (cw *clickHouseWaiter) connectOnce() error {
+	log := logger.Function("connectOnce").
+		Field("waiter", "ClickHouseWaiter")
+	// the server must answer a ping...
+	pingURL := cw.baseURL
+	pingURL.Path = clickHousePingPath
+	reply, err := cw.request(pingURL)
+	if err != nil {
+		log.Err(err).
+			Error("error pinging server")
+		return err
+	}
+	if reply != clickHousePingReply {
+		log.Field("reply", reply).
+			Error("unexpected reply to ping")
+		return ErrConnection
+	}
+	// ...and a query
+	reply, err = cw.query(clickHousePingQuery)
+	if err != nil {
+		log.Err(err).
+			Error("error querying server")
+		return err
+	}
+	if reply != "1" {
+		log.Field("reply", reply).
+			Error("unexpected reply to query")
+		return ErrConnection
+	}
+	if cw.cluster == "" {
+		// we're good
+		return nil
+	}
+	// every replica of the cluster must be reachable
+	replicas, err := cw.query(fmt.Sprintf(clickHouseReplicasQuery, cw.cluster))
+	if err != nil {
+		log.Err(err).
+			Field("cluster", cw.cluster).
+			Error("error querying cluster replicas")
+		return err
+	}
+	reachable, err := cw.query(fmt.Sprintf(clickHouseReachableQuery, cw.cluster))
+	if err != nil {
+		log.Err(err).
+			Field("cluster", cw.cluster).
+			Error("error querying cluster replicas")
+		return err
+	}
+	if replicas == "0" || replicas != reachable {
+		log.Fields(map[string]interface{}{
+			"cluster":   cw.cluster,
+			"replicas":  replicas,
+			"reachable": reachable,
+		}).
+			Error("not all cluster replicas are reachable")
+		return ErrClickHouseCluster
+	}
+	log.Fields(map[string]interface{}{
+		"cluster":  cw.cluster,
+		"replicas": replicas,
+	}).
+		Info("all cluster replicas are reachable")
+	return nil
+}
+
+// query runs a query over the HTTP interface and returns its trimmed TabSeparated result
+func (cw *clickHouseWaiter) query(query string) (string, error) {
+	queryURL := cw.baseURL
+	queryURL.Path = "/"
+	queryURL.RawQuery = url.Values{"query": []string{query}}.Encode()
+	return cw.request(queryURL)
+}
+
+func (cw *clickHouseWaiter) request(reqUrl url.URL) (string, error) {
+	log := logger.Function("request").
+		Field("waiter", "ClickHouseWaiter").
+		Field("path", reqUrl.Path)
+	req, err := http.NewRequest(http.MethodGet, reqUrl.String(), nil)
+	if err != nil {
+		log.Err(err).
+			Error("error creating new request")
+		return "", err
+	}
+	if cw.user != nil {
+		req.Header.Set(clickHouseUserHeader, cw.user.Username())
+		password, ok := cw.user.Password()
+		if ok {
+			req.Header.Set(clickHouseKeyHeader, password)
+		}
+	}
+	res, err := cw.client.Do(req)
+	if err != nil {
+		log.Err(err).
+			Error("error executing request")
+		return "", err
+	}
+	defer func() {
+		err = res.Body.Close()
+		if err != nil {
+			log.Err(err).
+				Error("error closing response body")
+		}
+	}()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		log.Err(err).
+			Error("error reading response body")
+		return "", err
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		log.Field("body", strings.TrimSpace(string(body))).
+			Errorf("request error; code: %d, status: %s", res.StatusCode, res.Status)
+		return "", ErrConnection
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+func (cw *clickHouseWaiter) delayOnce() {
+	log := logger.Function("delayOnce").
+		Field("waiter", "ClickHouseWaiter")
+	log.Field("delay", cw.retryDelay.String()).
+		Info("delaying until next attempt")
+	<-cw.ticker.C
+}
@@ -0,0 +1,274 @@
+package waiter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/neflyte/gowait/config"
+	"github.com/neflyte/gowait/lib/logger"
+	"github.com/neflyte/gowait/lib/utils"
+)
+
+// url: consul://token@host:8500/?service=name&passing=2
+
+const (
+	ConsulParamSecure  = "secure"
+	ConsulParamService = "service"
+	ConsulParamPassing = "passing"
+
+	consulPort          = "8500"
+	consulLeaderPath    = "/v1/status/leader"
+	consulServicePath   = "/v1/health/service/"
+	consulTokenHeader   = "X-Consul-Token"
+	consulPassingFilter = "passing"
+)
+
+var (
+	ErrConsulNoLeader = errors.New("consul cluster has no leader")
+	ErrConsulPassing  = errors.New("not enough service instances are passing their health checks")
+)
+
+type consulWaiter struct {
+	ticker     *time.Ticker
+	client     *http.Client
+	baseURL    url.URL
+	token      string
+	service    string
+	passing    int
+	attempts   int
+	retryDelay time.Duration
+}
+
+var _ prober = (*consulWaiter)(nil)
+
+func NewConsulWaiter() Waiter {
+	return &consulWaiter{
+		client:     http.DefaultClient,
+		baseURL:    url.URL{},
+		token:      "",
+		service:    "",
+		passing:    1,
+		attempts:   0,
+		retryDelay: config.RetryDelayDefault,
+		ticker:     time.NewTicker(config.RetryDelayDefault),
+	}
+}
+
+func (cw *consulWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
+	log := logger.Function("Wait").
+		Field("waiter", "ConsulWaiter")
+	err := cw.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
+	success := false
+	startTime := time.Now()
+	log.Field("retryDelay", retryDelay.String()).
+		Info("Using retry delay")
+	cw.ticker = time.NewTicker(retryDelay)
+	cw.retryDelay = retryDelay
+	urlStr := utils.SanitizedURLString(url)
+	cw.attempts = 0
+	for cw.attempts < retryLimit {
+		log.Field("url", urlStr).
+			Infof("[%d/%d] Connecting", cw.attempts+1, retryLimit)
+		err = cw.connectOnce()
+		cw.attempts++ // no matter what happens, we made an attempt
+		if err != nil {
+			if cw.attempts >= retryLimit {
+				log.Err(err).
+					Error("Connect error: retry limit reached; giving up")
+				break
+			}
+			log.Err(err).
+				Error("Connect error; delaying until next retry")
+			cw.delayOnce()
+			continue
+		}
+		// we're good
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    cw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Info("Successfully connected")
+		success = true
+		break
+	}
+	if !success {
+		errStr := fmt.Sprintf("Unable to connect to '%s' after %d attempts; elapsed time: %s", urlStr, cw.attempts, time.Since(startTime).String())
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    cw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Error("Unable to connect")
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// probe makes a single attempt without retrying or delaying
+func (cw *consulWaiter) probe(url url.URL) error {
+	err := cw.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return cw.connectOnce()
+}
+
+// parseOptions builds the HTTP API url and reads the gowait-specific query parameters from the url
+func (cw *consulWaiter) parseOptions(consulUrl url.URL) error {
+	query := consulUrl.Query()
+	client, err := newHTTPClient(query)
+	if err != nil {
+		return err
+	}
+	cw.client = client
+	cw.baseURL = url.URL{
+		Scheme: "http",
+		Host:   consulUrl.Host,
+	}
+	rawSecure := query.Get(ConsulParamSecure)
+	if rawSecure != "" {
+		secure, err := strconv.ParseBool(rawSecure)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidOptions, ConsulParamSecure, err.Error())
+		}
+		if secure {
+			cw.baseURL.Scheme = "https"
+		}
+	}
+	if consulUrl.Port() == "" {
+		cw.baseURL.Host = net.JoinHostPort(consulUrl.Hostname(), consulPort)
+	}
+	// the secret is the ACL token
+	cw.token = ""
+	if consulUrl.User != nil {
+		cw.token, _ = consulUrl.User.Password()
+	}
+	cw.service = query.Get(ConsulParamService)
+	cw.passing = 1
+	rawPassing := query.Get(ConsulParamPassing)
+	if rawPassing != "" {
+		if cw.service == "" {
+			return fmt.Errorf("%w: %s requires %s", ErrInvalidOptions, ConsulParamPassing, ConsulParamService)
+		}
+		passing, err := strconv.Atoi(rawPassing)
+		if err != nil || passing < 1 {
+			return fmt.Errorf("%w: %s must be a positive integer", ErrInvalidOptions, ConsulParamPassing)
+		}
+		cw.passing = passing
+	}
+	return nil
+}
+
+func (cw *consulWaiter) connectOnce() error {
+	log := logger.Function("connectOnce").
+		Field("waiter", "ConsulWaiter")
+	// the cluster must have elected a leader...
+	leader := ""
+	leaderURL := cw.baseURL
+	leaderURL.Path = consulLeaderPath
+	err := cw.request(leaderURL, &leader)
+	if err != nil {
+		log.Err(err).
+			Error("error querying cluster leader")
+		return err
+	}
+	if leader == "" {
+		log.Error("consul cluster has no leader")
+		return ErrConsulNoLeader
+	}
+	log.Field("leader", leader).
+		Info("consul cluster has a leader")
+	if cw.service == "" {
+		// we're good
+		return nil
+	}
+	// ...and enough instances of the service must be passing their health checks
+	instances := make([]json.RawMessage, 0)
+	serviceURL := cw.baseURL
+	serviceURL.Path = consulServicePath + cw.service
+	serviceURL.RawQuery = consulPassingFilter
+	err = cw.request(serviceURL, &instances)
+	if err != nil {
+		log.Err(err).
+			Field("service", cw.service).
+			Error("error querying service health")
+		return err
+	}
+	if len(instances) < cw.passing {
+		log.Fields(map[string]interface{}{
+			"service":  cw.service,
+			"passing":  len(instances),
+			"required": cw.passing,
+		}).
+			Error("not enough service instances are passing their health checks")
+		return ErrConsulPassing
+	}
+	log.Fields(map[string]interface{}{
+		"service": cw.service,
+		"passing": len(instances),
+	}).
+		Info("enough service instances are passing their health checks")
+	return nil
+}
+
+// request executes a GET request and decodes the JSON response into result
+func (cw *consulWaiter) request(reqUrl url.URL, result interface{}) error {
+	log := logger.Function("request").
+		Field("waiter", "ConsulWaiter").
+		Field("path", reqUrl.Path)
+	req, err := http.NewRequest(http.MethodGet, reqUrl.String(), nil)
+	if err != nil {
+		log.Err(err).
+			Error("error creating new request")
+		return err
+	}
+	if cw.token != "" {
+		req.Header.Set(consulTokenHeader, cw.token)
+	}
+	res, err := cw.client.Do(req)
+	if err != nil {
+		log.Err(err).
+			Error("error executing request")
+		return err
+	}
+	defer func() {
+		err = res.Body.Close()
+		if err != nil {
+			log.Err(err).
+				Error("error closing response body")
+		}
+	}()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		log.Errorf("request error; code: %d, status: %s", res.StatusCode, res.Status)
+		return ErrConnection
+	}
+	err = json.NewDecoder(res.Body).Decode(result)
+	if err != nil {
+		log.Err(err).
+			Error("error decoding response body")
+		return err
+	}
+	return nil
+}
+
+func (cw *consulWaiter) delayOnce() {
+	log := logger.Function("delayOnce").
+		Field("waiter", "ConsulWaiter")
+	log.Field("delay", cw.retryDelay.String()).
+		Info("delaying until next attempt")
+	<-cw.ticker.C
+}
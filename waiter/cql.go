@@ -0,0 +1,482 @@
+package waiter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/neflyte/gowait/config"
+	"github.com/neflyte/gowait/lib/logger"
+	"github.com/neflyte/gowait/lib/utils"
+)
+
+// url: cql://user@host:9042/?keyspace=name
+
+const (
+	CQLParamKeyspace = "keyspace"
+
+	cqlPort = "9042"
+
+	// native protocol v4 framing
+	cqlVersion      = 0x04
+	cqlHeaderLength = 9
+	// cqlResponseFlag marks the version byte of a response frame
+	cqlResponseFlag = 0x80
+	// cqlMaxBody is the largest response body that is read; the responses gowait asks for are far smaller
+	cqlMaxBody = 256 * 1024
+
+	cqlOpError        = 0x00
+	cqlOpStartup      = 0x01
+	cqlOpReady        = 0x02
+	cqlOpAuthenticate = 0x03
+	cqlOpQuery        = 0x07
+	cqlOpResult       = 0x08
+	cqlOpAuthResponse = 0x0F
+	cqlOpAuthSuccess  = 0x10
+
+	cqlConsistencyOne      = 0x0001
+	cqlQuerySkipMeta       = 0x02
+	cqlResultRows          = 0x0002
+	cqlRowsGlobalTableSpec = 0x0001
+	cqlRowsHasMorePages    = 0x0002
+	cqlRowsNoMetadata      = 0x0004
+
+	// option ids of the column types which carry further type information
+	cqlTypeCustom = 0x0000
+	cqlTypeList   = 0x0020
+	cqlTypeMap    = 0x0021
+	cqlTypeSet    = 0x0022
+	cqlTypeUDT    = 0x0030
+	cqlTypeTuple  = 0x0031
+
+	cqlKeyspaceQuery = "SELECT keyspace_name FROM system_schema.keyspaces WHERE keyspace_name = '%s'"
+)
+
+var (
+	ErrCQLProtocol     = errors.New("unexpected CQL protocol response")
+	ErrCQLAuthRequired = errors.New("server requires authentication but no credentials were given")
+	ErrCQLNoKeyspace   = errors.New("keyspace does not exist")
+
+	// cqlKeyspaceName restricts keyspace names to the characters Cassandra allows
+	cqlKeyspaceName = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+)
+
+type cqlWaiter struct {
+	ticker     *time.Ticker
+	user       *url.Userinfo
+	host       string
+	keyspace   string
+	attempts   int
+	retryDelay time.Duration
+}
+
+var _ prober = (*cqlWaiter)(nil)
+
+func NewCQLWaiter() Waiter {
+	return &cqlWaiter{
+		user:       nil,
+		host:       "",
+		keyspace:   "",
+		attempts:   0,
+		retryDelay: config.RetryDelayDefault,
+		ticker:     time.NewTicker(config.RetryDelayDefault),
+	}
+}
+
+func (cw *cqlWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
+	log := logger.Function("Wait").
+		Field("waiter", "CQLWaiter")
+	err := cw.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
+	success := false
+	startTime := time.Now()
+	log.Field("retryDelay", retryDelay.String()).
+		Info("Using retry delay")
+	cw.ticker = time.NewTicker(retryDelay)
+	cw.retryDelay = retryDelay
+	urlStr := utils.SanitizedURLString(url)
+	cw.attempts = 0
+	for cw.attempts < retryLimit {
+		log.Field("url", urlStr).
+			Infof("[%d/%d] Connecting", cw.attempts+1, retryLimit)
+		err = cw.connectOnce()
+		cw.attempts++ // no matter what happens, we made an attempt
+		if err != nil {
+			if cw.attempts >= retryLimit {
+				log.Err(err).
+					Error("Connect error: retry limit reached; giving up")
+				break
+			}
+			log.Err(err).
+				Error("Connect error; delaying until next retry")
+			cw.delayOnce()
+			continue
+		}
+		// we're good
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    cw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Info("Successfully connected")
+		success = true
+		break
+	}
+	if !success {
+		errStr := fmt.Sprintf("Unable to connect to '%s' after %d attempts; elapsed time: %s", urlStr, cw.attempts, time.Since(startTime).String())
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    cw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Error("Unable to connect")
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// probe makes a single attempt without retrying or delaying
+func (cw *cqlWaiter) probe(url url.URL) error {
+	err := cw.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return cw.connectOnce()
+}
+
+// parseOptions reads the server address, the credentials and the gowait-specific query parameters from the url
+func (cw *cqlWaiter) parseOptions(cqlUrl url.URL) error {
+	cw.keyspace = cqlUrl.Query().Get(CQLParamKeyspace)
+	if cw.keyspace != "" && !cqlKeyspaceName.MatchString(cw.keyspace) {
+		return fmt.Errorf("%w: invalid %s '%s'", ErrInvalidOptions, CQLParamKeyspace, cw.keyspace)
+	}
+	cw.user = cqlUrl.User
+	cw.host = cqlUrl.Host
+	if cqlUrl.Port() == "" {
+		cw.host = net.JoinHostPort(cqlUrl.Hostname(), cqlPort)
+	}
+	return nil
+}
+
+func (cw *cqlWaiter) connectOnce() error {
+	log := logger.Function("connectOnce").
+		Field("waiter", "CQLWaiter").
+		Field("host", cw.host)
+	conn, err := net.DialTimeout("tcp", cw.host, protocolTimeout)
+	if err != nil {
+		log.Err(err).
+			Error("unable to connect to tcp address")
+		return err
+	}
+	defer func() {
+		err = conn.Close()
+		if err != nil {
+			log.Err(err).
+				Error("error closing tcp connection")
+		}
+	}()
+	err = conn.SetDeadline(time.Now().Add(protocolTimeout))
+	if err != nil {
+		log.Err(err).
+			Error("error setting connection deadline")
+		return err
+	}
+	// STARTUP; the server answers READY or asks us to AUTHENTICATE
+	startup := new(bytes.Buffer)
+	cqlWriteStringMap(startup, map[string]string{"CQL_VERSION": "3.0.0"})
+	opcode, body, err := cqlExchange(conn, cqlOpStartup, startup.Bytes())
+	if err != nil {
+		log.Err(err).
+			Error("error sending STARTUP")
+		return err
+	}
+	if opcode == cqlOpAuthenticate {
+		err = cw.authenticate(conn, body)
+		if err != nil {
+			return err
+		}
+	} else if opcode != cqlOpReady {
+		log.Field("opcode", opcode).
+			Error("unexpected response to STARTUP")
+		return ErrCQLProtocol
+	}
+	log.Info("server is ready")
+	if cw.keyspace == "" {
+		// we're good
+		return nil
+	}
+	// the keyspace must exist
+	rows, err := cw.countRows(conn, fmt.Sprintf(cqlKeyspaceQuery, cw.keyspace))
+	if err != nil {
+		log.Err(err).
+			Error("error querying keyspaces")
+		return err
+	}
+	if rows == 0 {
+		log.Field("keyspace", cw.keyspace).
+			Error("keyspace does not exist")
+		return ErrCQLNoKeyspace
+	}
+	log.Field("keyspace", cw.keyspace).
+		Info("keyspace exists")
+	return nil
+}
+
+// authenticate answers an AUTHENTICATE with SASL PLAIN credentials, as PasswordAuthenticator expects
+func (cw *cqlWaiter) authenticate(conn net.Conn, body []byte) error {
+	log := logger.Function("authenticate").
+		Field("waiter", "CQLWaiter")
+	authenticator, _ := cqlReadString(bytes.NewReader(body))
+	log.Field("authenticator", authenticator).
+		Debug("server requested authentication")
+	if cw.user == nil {
+		log.Error("server requires authentication but no credentials were given")
+		return ErrCQLAuthRequired
+	}
+	password, _ := cw.user.Password()
+	token := []byte("\x00" + cw.user.Username() + "\x00" + password)
+	response := new(bytes.Buffer)
+	_ = binary.Write(response, binary.BigEndian, int32(len(token)))
+	response.Write(token)
+	opcode, _, err := cqlExchange(conn, cqlOpAuthResponse, response.Bytes())
+	if err != nil {
+		log.Err(err).
+			Error("error authenticating")
+		return err
+	}
+	if opcode != cqlOpAuthSuccess {
+		log.Field("opcode", opcode).
+			Error("unexpected response to AUTH_RESPONSE")
+		return ErrCQLProtocol
+	}
+	return nil
+}
+
+// countRows runs a query and returns the number of rows in its result; the query asks the server to skip the result
+// metadata, but metadata which is sent anyway is skipped over
+func (cw *cqlWaiter) countRows(conn net.Conn, query string) (int32, error) {
+	request := new(bytes.Buffer)
+	_ = binary.Write(request, binary.BigEndian, int32(len(query)))
+	request.WriteString(query)
+	_ = binary.Write(request, binary.BigEndian, uint16(cqlConsistencyOne))
+	request.WriteByte(cqlQuerySkipMeta)
+	opcode, body, err := cqlExchange(conn, cqlOpQuery, request.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	if opcode != cqlOpResult {
+		return 0, fmt.Errorf("%w: opcode %d", ErrCQLProtocol, opcode)
+	}
+	// <kind><flags><columns_count>[<paging_state>]<rows_count>
+	reader := bytes.NewReader(body)
+	header := struct {
+		Kind    int32
+		Flags   int32
+		Columns int32
+	}{}
+	err = binary.Read(reader, binary.BigEndian, &header)
+	if err != nil {
+		return 0, err
+	}
+	if header.Kind != cqlResultRows {
+		return 0, fmt.Errorf("%w: result kind %d", ErrCQLProtocol, header.Kind)
+	}
+	if header.Flags&cqlRowsHasMorePages != 0 {
+		pagingStateLength := int32(0)
+		err = binary.Read(reader, binary.BigEndian, &pagingStateLength)
+		if err != nil {
+			return 0, err
+		}
+		if pagingStateLength > 0 {
+			_, err = reader.Seek(int64(pagingStateLength), io.SeekCurrent)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	if header.Flags&cqlRowsNoMetadata == 0 {
+		err = cqlSkipColumnSpecs(reader, header.Flags, header.Columns)
+		if err != nil {
+			return 0, err
+		}
+	}
+	rows := int32(0)
+	err = binary.Read(reader, binary.BigEndian, &rows)
+	return rows, err
+}
+
+func (cw *cqlWaiter) delayOnce() {
+	log := logger.Function("delayOnce").
+		Field("waiter", "CQLWaiter")
+	log.Field("delay", cw.retryDelay.String()).
+		Info("delaying until next attempt")
+	<-cw.ticker.C
+}
+
+// cqlExchange sends a request frame and returns the opcode and body of the response frame; an ERROR response is
+// returned as an error
+func cqlExchange(conn net.Conn, opcode byte, body []byte) (byte, []byte, error) {
+	frame := new(bytes.Buffer)
+	frame.Write([]byte{cqlVersion, 0x00, 0x00, 0x00, opcode})
+	_ = binary.Write(frame, binary.BigEndian, int32(len(body)))
+	frame.Write(body)
+	_, err := conn.Write(frame.Bytes())
+	if err != nil {
+		return 0, nil, err
+	}
+	header := make([]byte, cqlHeaderLength)
+	_, err = io.ReadFull(conn, header)
+	if err != nil {
+		return 0, nil, err
+	}
+	if header[0] != cqlVersion|cqlResponseFlag {
+		return 0, nil, fmt.Errorf("%w: version byte 0x%02x", ErrCQLProtocol, header[0])
+	}
+	bodyLength := binary.BigEndian.Uint32(header[5:])
+	if bodyLength > cqlMaxBody {
+		return 0, nil, fmt.Errorf("%w: body length %d exceeds %d", ErrCQLProtocol, bodyLength, cqlMaxBody)
+	}
+	responseBody := make([]byte, bodyLength)
+	_, err = io.ReadFull(conn, responseBody)
+	if err != nil {
+		return 0, nil, err
+	}
+	if header[4] == cqlOpError {
+		reader := bytes.NewReader(responseBody)
+		code := int32(0)
+		_ = binary.Read(reader, binary.BigEndian, &code)
+		message, _ := cqlReadString(reader)
+		return 0, nil, fmt.Errorf("CQL error 0x%04x: %s", code, message)
+	}
+	return header[4], responseBody, nil
+}
+
+// cqlSkipColumnSpecs reads past the global table spec and the column specs of a Rows result
+func cqlSkipColumnSpecs(reader io.Reader, flags int32, columns int32) error {
+	if columns < 0 {
+		return fmt.Errorf("%w: column count %d", ErrCQLProtocol, columns)
+	}
+	globalTableSpec := flags&cqlRowsGlobalTableSpec != 0
+	if globalTableSpec {
+		// <keyspace><table>
+		for i := 0; i < 2; i++ {
+			_, err := cqlReadString(reader)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for column := int32(0); column < columns; column++ {
+		// [<keyspace><table>]<name><type>
+		names := 3
+		if globalTableSpec {
+			names = 1
+		}
+		for i := 0; i < names; i++ {
+			_, err := cqlReadString(reader)
+			if err != nil {
+				return err
+			}
+		}
+		err := cqlSkipOption(reader)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cqlSkipOption reads past a column type option, including the types nested in collection, UDT and tuple types
+func cqlSkipOption(reader io.Reader) error {
+	id := uint16(0)
+	err := binary.Read(reader, binary.BigEndian, &id)
+	if err != nil {
+		return err
+	}
+	switch id {
+	case cqlTypeCustom:
+		_, err = cqlReadString(reader)
+		return err
+	case cqlTypeList, cqlTypeSet:
+		return cqlSkipOption(reader)
+	case cqlTypeMap:
+		err = cqlSkipOption(reader)
+		if err != nil {
+			return err
+		}
+		return cqlSkipOption(reader)
+	case cqlTypeUDT:
+		// <keyspace><name><n>(<field name><field type>)*n
+		for i := 0; i < 2; i++ {
+			_, err = cqlReadString(reader)
+			if err != nil {
+				return err
+			}
+		}
+		fields := uint16(0)
+		err = binary.Read(reader, binary.BigEndian, &fields)
+		if err != nil {
+			return err
+		}
+		for field := uint16(0); field < fields; field++ {
+			_, err = cqlReadString(reader)
+			if err != nil {
+				return err
+			}
+			err = cqlSkipOption(reader)
+			if err != nil {
+				return err
+			}
+		}
+	case cqlTypeTuple:
+		// <n><type>*n
+		types := uint16(0)
+		err = binary.Read(reader, binary.BigEndian, &types)
+		if err != nil {
+			return err
+		}
+		for i := uint16(0); i < types; i++ {
+			err = cqlSkipOption(reader)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func cqlWriteStringMap(buf *bytes.Buffer, values map[string]string) {
+	_ = binary.Write(buf, binary.BigEndian, uint16(len(values)))
+	for key, value := range values {
+		cqlWriteString(buf, key)
+		cqlWriteString(buf, value)
+	}
+}
+
+func cqlWriteString(buf *bytes.Buffer, value string) {
+	_ = binary.Write(buf, binary.BigEndian, uint16(len(value)))
+	buf.WriteString(value)
+}
+
+func cqlReadString(reader io.Reader) (string, error) {
+	length := uint16(0)
+	err := binary.Read(reader, binary.BigEndian, &length)
+	if err != nil {
+		return "", err
+	}
+	value := make([]byte, length)
+	_, err = io.ReadFull(reader, value)
+	return string(value), err
+}
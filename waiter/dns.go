@@ -0,0 +1,261 @@
+package waiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neflyte/gowait/config"
+	"github.com/neflyte/gowait/lib/logger"
+	"github.com/neflyte/gowait/lib/utils"
+)
+
+// url: dns://resolver:53/name?type=SRV&expect=target:port&min=3
+// url: dns:///name?type=A (uses the system resolver)
+
+const (
+	DNSParamType   = "type"
+	DNSParamExpect = "expect"
+	DNSParamMin    = "min"
+
+	DNSTypeA    = "A"
+	DNSTypeAAAA = "AAAA"
+	DNSTypeSRV  = "SRV"
+	DNSTypeTXT  = "TXT"
+
+	dnsPort = "53"
+)
+
+var (
+	ErrDNSTooFewRecords = errors.New("name resolved to too few records")
+	ErrDNSNoMatch       = errors.New("name did not resolve to the expected value")
+)
+
+type dnsWaiter struct {
+	ticker     *time.Ticker
+	resolver   *net.Resolver
+	name       string
+	recordType string
+	expect     string
+	minRecords int
+	attempts   int
+	retryDelay time.Duration
+}
+
+var _ prober = (*dnsWaiter)(nil)
+
+func NewDNSWaiter() Waiter {
+	return &dnsWaiter{
+		resolver:   net.DefaultResolver,
+		name:       "",
+		recordType: DNSTypeA,
+		expect:     "",
+		minRecords: 1,
+		attempts:   0,
+		retryDelay: config.RetryDelayDefault,
+		ticker:     time.NewTicker(config.RetryDelayDefault),
+	}
+}
+
+func (dw *dnsWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
+	log := logger.Function("Wait").
+		Field("waiter", "DNSWaiter")
+	err := dw.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
+	success := false
+	startTime := time.Now()
+	log.Field("retryDelay", retryDelay.String()).
+		Info("Using retry delay")
+	dw.ticker = time.NewTicker(retryDelay)
+	dw.retryDelay = retryDelay
+	urlStr := utils.SanitizedURLString(url)
+	dw.attempts = 0
+	for dw.attempts < retryLimit {
+		log.Field("url", urlStr).
+			Infof("[%d/%d] Resolving", dw.attempts+1, retryLimit)
+		err = dw.connectOnce()
+		dw.attempts++ // no matter what happens, we made an attempt
+		if err != nil {
+			if dw.attempts >= retryLimit {
+				log.Err(err).
+					Error("Resolve error: retry limit reached; giving up")
+				break
+			}
+			log.Err(err).
+				Error("Resolve error; delaying until next retry")
+			dw.delayOnce()
+			continue
+		}
+		// we're good
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    dw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Info("Successfully resolved")
+		success = true
+		break
+	}
+	if !success {
+		errStr := fmt.Sprintf("Unable to resolve '%s' after %d attempts; elapsed time: %s", urlStr, dw.attempts, time.Since(startTime).String())
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    dw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Error("Unable to resolve")
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// probe makes a single attempt without retrying or delaying
+func (dw *dnsWaiter) probe(url url.URL) error {
+	err := dw.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return dw.connectOnce()
+}
+
+// parseOptions sets up the resolver and reads the gowait-specific query parameters from the url
+func (dw *dnsWaiter) parseOptions(dnsUrl url.URL) error {
+	query := dnsUrl.Query()
+	dw.name = strings.TrimPrefix(dnsUrl.Path, "/")
+	if dw.name == "" {
+		return fmt.Errorf("%w: no name to resolve in url", ErrInvalidOptions)
+	}
+	dw.recordType = strings.ToUpper(query.Get(DNSParamType))
+	switch dw.recordType {
+	case "":
+		dw.recordType = DNSTypeA
+	case DNSTypeA, DNSTypeAAAA, DNSTypeSRV, DNSTypeTXT:
+	default:
+		return fmt.Errorf("%w: unknown %s '%s'", ErrInvalidOptions, DNSParamType, dw.recordType)
+	}
+	dw.expect = query.Get(DNSParamExpect)
+	dw.minRecords = 1
+	rawMin := query.Get(DNSParamMin)
+	if rawMin != "" {
+		minRecords, err := strconv.Atoi(rawMin)
+		if err != nil || minRecords < 1 {
+			return fmt.Errorf("%w: %s must be a positive integer", ErrInvalidOptions, DNSParamMin)
+		}
+		dw.minRecords = minRecords
+	}
+	dw.resolver = net.DefaultResolver
+	if dnsUrl.Host != "" {
+		// send every query to the resolver in the url
+		resolverAddr := dnsUrl.Host
+		if dnsUrl.Port() == "" {
+			resolverAddr = net.JoinHostPort(dnsUrl.Hostname(), dnsPort)
+		}
+		dw.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network string, _ string) (net.Conn, error) {
+				dialer := net.Dialer{Timeout: protocolTimeout}
+				return dialer.DialContext(ctx, network, resolverAddr)
+			},
+		}
+	}
+	return nil
+}
+
+func (dw *dnsWaiter) connectOnce() error {
+	log := logger.Function("connectOnce").
+		Field("waiter", "DNSWaiter").
+		Fields(map[string]interface{}{
+			"name": dw.name,
+			"type": dw.recordType,
+		})
+	records, err := dw.lookup()
+	if err != nil {
+		log.Err(err).
+			Error("error resolving name")
+		return err
+	}
+	if len(records) < dw.minRecords {
+		log.Fields(map[string]interface{}{
+			"records": strings.Join(records, ","),
+			"min":     dw.minRecords,
+		}).
+			Error("name resolved to too few records")
+		return ErrDNSTooFewRecords
+	}
+	if dw.expect != "" {
+		found := false
+		for _, record := range records {
+			if record == dw.expect {
+				found = true
+				break
+			}
+		}
+		if !found {
+			log.Fields(map[string]interface{}{
+				"records": strings.Join(records, ","),
+				"expect":  dw.expect,
+			}).
+				Error("name did not resolve to the expected value")
+			return ErrDNSNoMatch
+		}
+	}
+	log.Field("records", strings.Join(records, ",")).
+		Info("name resolved")
+	return nil
+}
+
+// lookup resolves the name and returns the records as strings; SRV records are returned as target:port
+func (dw *dnsWaiter) lookup() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), protocolTimeout)
+	defer cancel()
+	records := make([]string, 0)
+	switch dw.recordType {
+	case DNSTypeA, DNSTypeAAAA:
+		network := "ip4"
+		if dw.recordType == DNSTypeAAAA {
+			network = "ip6"
+		}
+		ips, err := dw.resolver.LookupIP(ctx, network, dw.name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			records = append(records, ip.String())
+		}
+	case DNSTypeSRV:
+		_, srvs, err := dw.resolver.LookupSRV(ctx, "", "", dw.name)
+		if err != nil {
+			return nil, err
+		}
+		for _, srv := range srvs {
+			target := strings.TrimSuffix(srv.Target, ".")
+			records = append(records, net.JoinHostPort(target, strconv.Itoa(int(srv.Port))))
+		}
+	case DNSTypeTXT:
+		txts, err := dw.resolver.LookupTXT(ctx, dw.name)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, txts...)
+	}
+	return records, nil
+}
+
+func (dw *dnsWaiter) delayOnce() {
+	log := logger.Function("delayOnce").
+		Field("waiter", "DNSWaiter")
+	log.Field("delay", dw.retryDelay.String()).
+		Info("delaying until next attempt")
+	<-dw.ticker.C
+}
@@ -0,0 +1,314 @@
+package waiter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neflyte/gowait/config"
+	"github.com/neflyte/gowait/lib/logger"
+	"github.com/neflyte/gowait/lib/utils"
+)
+
+// url: elasticsearch://user@host:9200/?status=green&indices=index1,index2&indexStatus=yellow
+// url: opensearch://user@host:9200/?status=green&indices=index1,index2&indexStatus=yellow
+
+const (
+	ElasticsearchParamSecure      = "secure"
+	ElasticsearchParamStatus      = "status"
+	ElasticsearchParamIndices     = "indices"
+	ElasticsearchParamIndexStatus = "indexStatus"
+
+	ElasticsearchStatusRed    = "red"
+	ElasticsearchStatusYellow = "yellow"
+	ElasticsearchStatusGreen  = "green"
+
+	elasticsearchPort       = "9200"
+	elasticsearchHealthPath = "/_cluster/health"
+)
+
+var (
+	ErrElasticsearchStatus  = errors.New("health status is below the required status")
+	ErrElasticsearchNoIndex = errors.New("index does not exist")
+
+	// elasticsearchStatusRank orders the health statuses from worst to best
+	elasticsearchStatusRank = map[string]int{
+		ElasticsearchStatusRed:    0,
+		ElasticsearchStatusYellow: 1,
+		ElasticsearchStatusGreen:  2,
+	}
+)
+
+// elasticsearchHealth is the subset of a cluster health response that gowait looks at
+type elasticsearchHealth struct {
+	Indices     map[string]elasticsearchHealth `json:"indices"`
+	ClusterName string                         `json:"cluster_name"`
+	Status      string                         `json:"status"`
+}
+
+type elasticsearchWaiter struct {
+	ticker      *time.Ticker
+	client      *http.Client
+	baseURL     url.URL
+	status      string
+	indexStatus string
+	indices     []string
+	attempts    int
+	retryDelay  time.Duration
+}
+
+var _ prober = (*elasticsearchWaiter)(nil)
+
+func NewElasticsearchWaiter() Waiter {
+	return &elasticsearchWaiter{
+		client:      http.DefaultClient,
+		baseURL:     url.URL{},
+		status:      ElasticsearchStatusYellow,
+		indexStatus: ElasticsearchStatusYellow,
+		indices:     make([]string, 0),
+		attempts:    0,
+		retryDelay:  config.RetryDelayDefault,
+		ticker:      time.NewTicker(config.RetryDelayDefault),
+	}
+}
+
+func (ew *elasticsearchWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
+	log := logger.Function("Wait").
+		Field("waiter", "ElasticsearchWaiter")
+	err := ew.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
+	success := false
+	startTime := time.Now()
+	log.Field("retryDelay", retryDelay.String()).
+		Info("Using retry delay")
+	ew.ticker = time.NewTicker(retryDelay)
+	ew.retryDelay = retryDelay
+	urlStr := utils.SanitizedURLString(url)
+	ew.attempts = 0
+	for ew.attempts < retryLimit {
+		log.Field("url", urlStr).
+			Infof("[%d/%d] Connecting", ew.attempts+1, retryLimit)
+		err = ew.connectOnce()
+		ew.attempts++ // no matter what happens, we made an attempt
+		if err != nil {
+			if ew.attempts >= retryLimit {
+				log.Err(err).
+					Error("Connect error: retry limit reached; giving up")
+				break
+			}
+			log.Err(err).
+				Error("Connect error; delaying until next retry")
+			ew.delayOnce()
+			continue
+		}
+		// we're good
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    ew.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Info("Successfully connected")
+		success = true
+		break
+	}
+	if !success {
+		errStr := fmt.Sprintf("Unable to connect to '%s' after %d attempts; elapsed time: %s", urlStr, ew.attempts, time.Since(startTime).String())
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    ew.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Error("Unable to connect")
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// probe makes a single attempt without retrying or delaying
+func (ew *elasticsearchWaiter) probe(url url.URL) error {
+	err := ew.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return ew.connectOnce()
+}
+
+// parseOptions builds the REST API url and reads the gowait-specific query parameters from the url
+func (ew *elasticsearchWaiter) parseOptions(esUrl url.URL) error {
+	query := esUrl.Query()
+	client, err := newHTTPClient(query)
+	if err != nil {
+		return err
+	}
+	ew.client = client
+	ew.baseURL = url.URL{
+		Scheme: "http",
+		User:   esUrl.User,
+		Host:   esUrl.Host,
+	}
+	rawSecure := query.Get(ElasticsearchParamSecure)
+	if rawSecure != "" {
+		secure, err := strconv.ParseBool(rawSecure)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidOptions, ElasticsearchParamSecure, err.Error())
+		}
+		if secure {
+			ew.baseURL.Scheme = "https"
+		}
+	}
+	if esUrl.Port() == "" {
+		ew.baseURL.Host = net.JoinHostPort(esUrl.Hostname(), elasticsearchPort)
+	}
+	ew.status = query.Get(ElasticsearchParamStatus)
+	if ew.status == "" {
+		ew.status = ElasticsearchStatusYellow
+	}
+	if _, ok := elasticsearchStatusRank[ew.status]; !ok {
+		return fmt.Errorf("%w: unknown %s '%s'", ErrInvalidOptions, ElasticsearchParamStatus, ew.status)
+	}
+	ew.indexStatus = query.Get(ElasticsearchParamIndexStatus)
+	if ew.indexStatus == "" {
+		ew.indexStatus = ew.status
+	}
+	if _, ok := elasticsearchStatusRank[ew.indexStatus]; !ok {
+		return fmt.Errorf("%w: unknown %s '%s'", ErrInvalidOptions, ElasticsearchParamIndexStatus, ew.indexStatus)
+	}
+	ew.indices = utils.SplitList(query.Get(ElasticsearchParamIndices))
+	return nil
+}
+
+func (ew *elasticsearchWaiter) connectOnce() error {
+	log := logger.Function("connectOnce").
+		Field("waiter", "ElasticsearchWaiter")
+	// the cluster must be healthy enough...
+	health := elasticsearchHealth{}
+	healthURL := ew.baseURL
+	healthURL.Path = elasticsearchHealthPath
+	_, err := ew.request(http.MethodGet, healthURL, &health)
+	if err != nil {
+		log.Err(err).
+			Error("error querying cluster health")
+		return err
+	}
+	if elasticsearchStatusRank[health.Status] < elasticsearchStatusRank[ew.status] {
+		log.Fields(map[string]interface{}{
+			"cluster":        health.ClusterName,
+			"status":         health.Status,
+			"requiredStatus": ew.status,
+		}).
+			Error("cluster health is below the required status")
+		return ErrElasticsearchStatus
+	}
+	log.Fields(map[string]interface{}{
+		"cluster": health.ClusterName,
+		"status":  health.Status,
+	}).
+		Info("cluster health is sufficient")
+	if len(ew.indices) == 0 {
+		// we're good
+		return nil
+	}
+	// ...every index must exist...
+	for _, index := range ew.indices {
+		indexURL := ew.baseURL
+		indexURL.Path = "/" + index
+		statusCode, err := ew.request(http.MethodHead, indexURL, nil)
+		if err != nil && statusCode != http.StatusNotFound {
+			log.Err(err).
+				Field("index", index).
+				Error("error checking index")
+			return err
+		}
+		if statusCode == http.StatusNotFound {
+			log.Field("index", index).
+				Error("index does not exist")
+			return fmt.Errorf("%w: %s", ErrElasticsearchNoIndex, index)
+		}
+	}
+	// ...and be healthy enough
+	indexHealth := elasticsearchHealth{}
+	indexHealthURL := ew.baseURL
+	indexHealthURL.Path = elasticsearchHealthPath + "/" + strings.Join(ew.indices, ",")
+	indexHealthURL.RawQuery = url.Values{"level": []string{"indices"}}.Encode()
+	_, err = ew.request(http.MethodGet, indexHealthURL, &indexHealth)
+	if err != nil {
+		log.Err(err).
+			Error("error querying index health")
+		return err
+	}
+	for _, index := range ew.indices {
+		status := indexHealth.Indices[index].Status
+		if elasticsearchStatusRank[status] < elasticsearchStatusRank[ew.indexStatus] {
+			log.Fields(map[string]interface{}{
+				"index":          index,
+				"status":         status,
+				"requiredStatus": ew.indexStatus,
+			}).
+				Error("index health is below the required status")
+			return ErrElasticsearchStatus
+		}
+	}
+	log.Field("indices", strings.Join(ew.indices, ",")).
+		Info("index health is sufficient")
+	return nil
+}
+
+// request executes a request and decodes the JSON response into result if it is non-nil; the status code is
+// returned even if the request was not successful
+func (ew *elasticsearchWaiter) request(method string, reqUrl url.URL, result interface{}) (int, error) {
+	log := logger.Function("request").
+		Field("waiter", "ElasticsearchWaiter").
+		Field("path", reqUrl.Path)
+	req, err := http.NewRequest(method, reqUrl.String(), nil)
+	if err != nil {
+		log.Err(err).
+			Error("error creating new request")
+		return 0, err
+	}
+	res, err := ew.client.Do(req)
+	if err != nil {
+		log.Err(err).
+			Error("error executing request")
+		return 0, err
+	}
+	defer func() {
+		err = res.Body.Close()
+		if err != nil {
+			log.Err(err).
+				Error("error closing response body")
+		}
+	}()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		log.Errorf("request error; code: %d, status: %s", res.StatusCode, res.Status)
+		return res.StatusCode, ErrConnection
+	}
+	if result != nil {
+		err = json.NewDecoder(res.Body).Decode(result)
+		if err != nil {
+			log.Err(err).
+				Error("error decoding response body")
+			return res.StatusCode, err
+		}
+	}
+	return res.StatusCode, nil
+}
+
+func (ew *elasticsearchWaiter) delayOnce() {
+	log := logger.Function("delayOnce").
+		Field("waiter", "ElasticsearchWaiter")
+	log.Field("delay", ew.retryDelay.String()).
+		Info("delaying until next attempt")
+	<-ew.ticker.C
+}
@@ -0,0 +1,247 @@
+package waiter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neflyte/gowait/config"
+	"github.com/neflyte/gowait/lib/logger"
+	"github.com/neflyte/gowait/lib/utils"
+)
+
+// url: etcd://host:2379/?secure=true
+
+const (
+	EtcdParamSecure = "secure"
+
+	etcdPort       = "2379"
+	etcdHealthPath = "/health"
+	etcdStatusPath = "/v3/maintenance/status"
+)
+
+var (
+	ErrEtcdUnhealthy = errors.New("etcd member is not healthy")
+	ErrEtcdNoLeader  = errors.New("etcd cluster has no leader")
+)
+
+// etcdHealth is the response of the /health endpoint
+type etcdHealth struct {
+	Health string `json:"health"`
+	Reason string `json:"reason"`
+}
+
+// etcdStatus is the subset of a maintenance Status response that gowait looks at; the gRPC gateway encodes
+// 64-bit integers as strings
+type etcdStatus struct {
+	Version string `json:"version"`
+	Leader  string `json:"leader"`
+}
+
+type etcdWaiter struct {
+	ticker     *time.Ticker
+	client     *http.Client
+	baseURL    url.URL
+	attempts   int
+	retryDelay time.Duration
+}
+
+var _ prober = (*etcdWaiter)(nil)
+
+func NewEtcdWaiter() Waiter {
+	return &etcdWaiter{
+		client:     http.DefaultClient,
+		baseURL:    url.URL{},
+		attempts:   0,
+		retryDelay: config.RetryDelayDefault,
+		ticker:     time.NewTicker(config.RetryDelayDefault),
+	}
+}
+
+func (ew *etcdWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
+	log := logger.Function("Wait").
+		Field("waiter", "EtcdWaiter")
+	err := ew.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
+	success := false
+	startTime := time.Now()
+	log.Field("retryDelay", retryDelay.String()).
+		Info("Using retry delay")
+	ew.ticker = time.NewTicker(retryDelay)
+	ew.retryDelay = retryDelay
+	urlStr := utils.SanitizedURLString(url)
+	ew.attempts = 0
+	for ew.attempts < retryLimit {
+		log.Field("url", urlStr).
+			Infof("[%d/%d] Connecting", ew.attempts+1, retryLimit)
+		err = ew.connectOnce()
+		ew.attempts++ // no matter what happens, we made an attempt
+		if err != nil {
+			if ew.attempts >= retryLimit {
+				log.Err(err).
+					Error("Connect error: retry limit reached; giving up")
+				break
+			}
+			log.Err(err).
+				Error("Connect error; delaying until next retry")
+			ew.delayOnce()
+			continue
+		}
+		// we're good
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    ew.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Info("Successfully connected")
+		success = true
+		break
+	}
+	if !success {
+		errStr := fmt.Sprintf("Unable to connect to '%s' after %d attempts; elapsed time: %s", urlStr, ew.attempts, time.Since(startTime).String())
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    ew.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Error("Unable to connect")
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// probe makes a single attempt without retrying or delaying
+func (ew *etcdWaiter) probe(url url.URL) error {
+	err := ew.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return ew.connectOnce()
+}
+
+// parseOptions builds the client url from the url
+func (ew *etcdWaiter) parseOptions(etcdUrl url.URL) error {
+	client, err := newHTTPClient(etcdUrl.Query())
+	if err != nil {
+		return err
+	}
+	ew.client = client
+	ew.baseURL = url.URL{
+		Scheme: "http",
+		Host:   etcdUrl.Host,
+	}
+	rawSecure := etcdUrl.Query().Get(EtcdParamSecure)
+	if rawSecure != "" {
+		secure, err := strconv.ParseBool(rawSecure)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidOptions, EtcdParamSecure, err.Error())
+		}
+		if secure {
+			ew.baseURL.Scheme = "https"
+		}
+	}
+	if etcdUrl.Port() == "" {
+		ew.baseURL.Host = net.JoinHostPort(etcdUrl.Hostname(), etcdPort)
+	}
+	return nil
+}
+
+func (ew *etcdWaiter) connectOnce() error {
+	log := logger.Function("connectOnce").
+		Field("waiter", "EtcdWaiter")
+	// the member must report itself healthy, which requires a leader...
+	health := etcdHealth{}
+	healthURL := ew.baseURL
+	healthURL.Path = etcdHealthPath
+	err := ew.request(http.MethodGet, healthURL, &health)
+	if err != nil {
+		log.Err(err).
+			Error("error querying member health")
+		return err
+	}
+	if health.Health != "true" {
+		log.Field("reason", health.Reason).
+			Error("etcd member is not healthy")
+		return ErrEtcdUnhealthy
+	}
+	// ...and the maintenance status must name the leader
+	status := etcdStatus{}
+	statusURL := ew.baseURL
+	statusURL.Path = etcdStatusPath
+	err = ew.request(http.MethodPost, statusURL, &status)
+	if err != nil {
+		log.Err(err).
+			Error("error querying maintenance status")
+		return err
+	}
+	if status.Leader == "" || status.Leader == "0" {
+		log.Field("version", status.Version).
+			Error("etcd cluster has no leader")
+		return ErrEtcdNoLeader
+	}
+	log.Fields(map[string]interface{}{
+		"version": status.Version,
+		"leader":  status.Leader,
+	}).
+		Info("etcd cluster has a leader")
+	return nil
+}
+
+// request executes a request and decodes the JSON response into result; POST requests send an empty JSON object
+// as the gRPC gateway expects
+func (ew *etcdWaiter) request(method string, reqUrl url.URL, result interface{}) error {
+	log := logger.Function("request").
+		Field("waiter", "EtcdWaiter").
+		Field("path", reqUrl.Path)
+	body := ""
+	if method == http.MethodPost {
+		body = "{}"
+	}
+	req, err := http.NewRequest(method, reqUrl.String(), strings.NewReader(body))
+	if err != nil {
+		log.Err(err).
+			Error("error creating new request")
+		return err
+	}
+	res, err := ew.client.Do(req)
+	if err != nil {
+		log.Err(err).
+			Error("error executing request")
+		return err
+	}
+	defer func() {
+		err = res.Body.Close()
+		if err != nil {
+			log.Err(err).
+				Error("error closing response body")
+		}
+	}()
+	// an unhealthy member answers /health with 503 and a JSON body, so decode the body regardless of the status
+	err = json.NewDecoder(res.Body).Decode(result)
+	if err != nil {
+		log.Err(err).
+			Errorf("error decoding response body; code: %d, status: %s", res.StatusCode, res.Status)
+		return err
+	}
+	return nil
+}
+
+func (ew *etcdWaiter) delayOnce() {
+	log := logger.Function("delayOnce").
+		Field("waiter", "EtcdWaiter")
+	log.Field("delay", ew.retryDelay.String()).
+		Info("delaying until next attempt")
+	<-ew.ticker.C
+}
@@ -0,0 +1,228 @@
+package waiter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/neflyte/gowait/config"
+	"github.com/neflyte/gowait/lib/logger"
+	"github.com/neflyte/gowait/lib/utils"
+)
+
+// url: exec:///usr/bin/pg_isready?arg=-h&arg=db&timeout=5s&match=accepting
+// url: exec:pg_isready?arg=-h&arg=db (looked up in PATH)
+
+const (
+	ExecParamArg     = "arg"
+	ExecParamTimeout = "timeout"
+	ExecParamMatch   = "match"
+)
+
+var (
+	ErrExecNoMatch = errors.New("command output did not match the expected pattern")
+)
+
+type execWaiter struct {
+	ticker     *time.Ticker
+	match      *regexp.Regexp
+	command    string
+	args       []string
+	timeout    time.Duration
+	attempts   int
+	retryDelay time.Duration
+}
+
+var _ prober = (*execWaiter)(nil)
+
+func NewExecWaiter() Waiter {
+	return &execWaiter{
+		match:      nil,
+		command:    "",
+		args:       make([]string, 0),
+		timeout:    protocolTimeout,
+		attempts:   0,
+		retryDelay: config.RetryDelayDefault,
+		ticker:     time.NewTicker(config.RetryDelayDefault),
+	}
+}
+
+func (ew *execWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
+	log := logger.Function("Wait").
+		Field("waiter", "ExecWaiter")
+	err := ew.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
+	success := false
+	startTime := time.Now()
+	log.Field("retryDelay", retryDelay.String()).
+		Info("Using retry delay")
+	ew.ticker = time.NewTicker(retryDelay)
+	ew.retryDelay = retryDelay
+	urlStr := utils.SanitizedURLString(url)
+	ew.attempts = 0
+	for ew.attempts < retryLimit {
+		log.Field("url", urlStr).
+			Infof("[%d/%d] Running", ew.attempts+1, retryLimit)
+		err = ew.runOnce()
+		ew.attempts++ // no matter what happens, we made an attempt
+		if err != nil {
+			if ew.attempts >= retryLimit {
+				log.Err(err).
+					Error("Command error: retry limit reached; giving up")
+				break
+			}
+			log.Err(err).
+				Error("Command error; delaying until next retry")
+			ew.delayOnce()
+			continue
+		}
+		// we're good
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    ew.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Info("Command succeeded")
+		success = true
+		break
+	}
+	if !success {
+		errStr := fmt.Sprintf("Command '%s' did not succeed after %d attempts; elapsed time: %s", urlStr, ew.attempts, time.Since(startTime).String())
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    ew.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Error("Command did not succeed")
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// probe makes a single attempt without retrying or delaying
+func (ew *execWaiter) probe(url url.URL) error {
+	err := ew.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return ew.runOnce()
+}
+
+// parseOptions reads the command, its arguments and the gowait-specific query parameters from the url
+func (ew *execWaiter) parseOptions(execUrl url.URL) error {
+	query := execUrl.Query()
+	// exec:name is looked up in PATH; exec:///path/to/name is run as-is
+	ew.command = execUrl.Opaque
+	if ew.command == "" {
+		if execUrl.Host != "" {
+			return fmt.Errorf("%w: exec urls must not have a host; use exec:///path/to/command or exec:command", ErrInvalidOptions)
+		}
+		ew.command = execUrl.Path
+	}
+	if ew.command == "" {
+		return fmt.Errorf("%w: no command in url", ErrInvalidOptions)
+	}
+	ew.args = query[ExecParamArg]
+	ew.timeout = protocolTimeout
+	rawTimeout := query.Get(ExecParamTimeout)
+	if rawTimeout != "" {
+		timeout, err := time.ParseDuration(rawTimeout)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("%w: %s must be a positive duration", ErrInvalidOptions, ExecParamTimeout)
+		}
+		ew.timeout = timeout
+	}
+	ew.match = nil
+	rawMatch := query.Get(ExecParamMatch)
+	if rawMatch != "" {
+		match, err := regexp.Compile(rawMatch)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidOptions, ExecParamMatch, err.Error())
+		}
+		ew.match = match
+	}
+	return nil
+}
+
+// runOnce runs the command without a shell; it succeeds if the command exits with code 0 before the timeout and
+// its standard output matches the pattern, if there is one
+func (ew *execWaiter) runOnce() error {
+	log := logger.Function("runOnce").
+		Field("waiter", "ExecWaiter").
+		Fields(map[string]interface{}{
+			"command": ew.command,
+			"args":    strings.Join(ew.args, " "),
+		})
+	ctx, cancel := context.WithTimeout(context.Background(), ew.timeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(ew.command, ew.args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	setProcessGroup(cmd)
+	err := cmd.Start()
+	if err != nil {
+		log.Err(err).
+			Error("unable to start command")
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	timedOut := false
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		timedOut = true
+		// kill the whole group; a child still holding stdout or stderr open would otherwise keep Wait from returning
+		killErr := killProcessGroup(cmd)
+		if killErr != nil {
+			log.Err(killErr).
+				Error("error killing command")
+		}
+		err = <-done
+	}
+	if timedOut {
+		log.Err(ctx.Err()).
+			Field("timeout", ew.timeout.String()).
+			Error("command timed out")
+		return ctx.Err()
+	}
+	if err != nil {
+		log.Err(err).
+			Field("stderr", strings.TrimSpace(stderr.String())).
+			Error("command failed")
+		return err
+	}
+	if ew.match != nil && !ew.match.Match(stdout.Bytes()) {
+		log.Fields(map[string]interface{}{
+			"stdout": strings.TrimSpace(stdout.String()),
+			"match":  ew.match.String(),
+		}).
+			Error("command output did not match the expected pattern")
+		return ErrExecNoMatch
+	}
+	log.Info("command exited successfully")
+	return nil
+}
+
+func (ew *execWaiter) delayOnce() {
+	log := logger.Function("delayOnce").
+		Field("waiter", "ExecWaiter")
+	log.Field("delay", ew.retryDelay.String()).
+		Info("delaying until next attempt")
+	<-ew.ticker.C
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package waiter
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup does nothing on Windows; only the command itself is killed on a timeout
+func setProcessGroup(_ *exec.Cmd) {}
+
+// killProcessGroup kills the command
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
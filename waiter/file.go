@@ -0,0 +1,310 @@
+package waiter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neflyte/gowait/config"
+	"github.com/neflyte/gowait/lib/logger"
+	"github.com/neflyte/gowait/lib/utils"
+)
+
+// url: file:///etc/tls/tls.crt?minSize=1&match=BEGIN%20CERTIFICATE
+// url: file:///var/lib/seed?type=dir&nonEmpty=true
+
+const (
+	FileParamType     = "type"
+	FileParamNonEmpty = "nonEmpty"
+	FileParamMinSize  = "minSize"
+	FileParamMatch    = "match"
+	FileParamSHA256   = "sha256"
+
+	FileTypeFile = "file"
+	FileTypeDir  = "dir"
+)
+
+var (
+	ErrFileType     = errors.New("path is not of the expected type")
+	ErrFileEmpty    = errors.New("path is empty")
+	ErrFileTooSmall = errors.New("file is smaller than the minimum size")
+	ErrFileNoMatch  = errors.New("file content did not match the expected pattern")
+	ErrFileChecksum = errors.New("file checksum did not match")
+)
+
+type fileWaiter struct {
+	ticker     *time.Ticker
+	match      *regexp.Regexp
+	path       string
+	fileType   string
+	sha256     string
+	minSize    int64
+	attempts   int
+	retryDelay time.Duration
+	nonEmpty   bool
+}
+
+var _ prober = (*fileWaiter)(nil)
+
+func NewFileWaiter() Waiter {
+	return &fileWaiter{
+		match:      nil,
+		path:       "",
+		fileType:   "",
+		sha256:     "",
+		minSize:    0,
+		nonEmpty:   false,
+		attempts:   0,
+		retryDelay: config.RetryDelayDefault,
+		ticker:     time.NewTicker(config.RetryDelayDefault),
+	}
+}
+
+func (fw *fileWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
+	log := logger.Function("Wait").
+		Field("waiter", "FileWaiter")
+	err := fw.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
+	success := false
+	startTime := time.Now()
+	log.Field("retryDelay", retryDelay.String()).
+		Info("Using retry delay")
+	fw.ticker = time.NewTicker(retryDelay)
+	fw.retryDelay = retryDelay
+	urlStr := utils.SanitizedURLString(url)
+	fw.attempts = 0
+	for fw.attempts < retryLimit {
+		log.Field("url", urlStr).
+			Infof("[%d/%d] Checking", fw.attempts+1, retryLimit)
+		err = fw.checkOnce()
+		fw.attempts++ // no matter what happens, we made an attempt
+		if err != nil {
+			if fw.attempts >= retryLimit {
+				log.Err(err).
+					Error("Check error: retry limit reached; giving up")
+				break
+			}
+			log.Err(err).
+				Error("Check error; delaying until next retry")
+			fw.delayOnce()
+			continue
+		}
+		// we're good
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    fw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Info("Successfully checked")
+		success = true
+		break
+	}
+	if !success {
+		errStr := fmt.Sprintf("Unable to check '%s' after %d attempts; elapsed time: %s", urlStr, fw.attempts, time.Since(startTime).String())
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    fw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Error("Unable to check")
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// probe makes a single attempt without retrying or delaying
+func (fw *fileWaiter) probe(url url.URL) error {
+	err := fw.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return fw.checkOnce()
+}
+
+// parseOptions reads the path and the gowait-specific query parameters from the url
+func (fw *fileWaiter) parseOptions(fileUrl url.URL) error {
+	query := fileUrl.Query()
+	if fileUrl.Host != "" && fileUrl.Host != "localhost" {
+		return fmt.Errorf("%w: file urls must not have a host; use file:///path", ErrInvalidOptions)
+	}
+	fw.path = fileUrl.Path
+	if fw.path == "" {
+		return fmt.Errorf("%w: no path in url", ErrInvalidOptions)
+	}
+	fw.fileType = query.Get(FileParamType)
+	switch fw.fileType {
+	case "", FileTypeFile, FileTypeDir:
+	default:
+		return fmt.Errorf("%w: unknown %s '%s'", ErrInvalidOptions, FileParamType, fw.fileType)
+	}
+	fw.nonEmpty = false
+	rawNonEmpty := query.Get(FileParamNonEmpty)
+	if rawNonEmpty != "" {
+		nonEmpty, err := strconv.ParseBool(rawNonEmpty)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidOptions, FileParamNonEmpty, err.Error())
+		}
+		fw.nonEmpty = nonEmpty
+	}
+	fw.minSize = 0
+	rawMinSize := query.Get(FileParamMinSize)
+	if rawMinSize != "" {
+		minSize, err := strconv.ParseInt(rawMinSize, 10, 64)
+		if err != nil || minSize < 0 {
+			return fmt.Errorf("%w: %s must be a non-negative integer", ErrInvalidOptions, FileParamMinSize)
+		}
+		fw.minSize = minSize
+	}
+	fw.match = nil
+	rawMatch := query.Get(FileParamMatch)
+	if rawMatch != "" {
+		match, err := regexp.Compile(rawMatch)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidOptions, FileParamMatch, err.Error())
+		}
+		fw.match = match
+	}
+	fw.sha256 = strings.ToLower(query.Get(FileParamSHA256))
+	if fw.sha256 != "" {
+		sum, err := hex.DecodeString(fw.sha256)
+		if err != nil || len(sum) != sha256.Size {
+			return fmt.Errorf("%w: %s must be a hex-encoded SHA-256 checksum", ErrInvalidOptions, FileParamSHA256)
+		}
+	}
+	if fw.fileType == FileTypeDir && (fw.minSize > 0 || fw.match != nil || fw.sha256 != "") {
+		return fmt.Errorf("%w: %s, %s and %s cannot be used with %s=%s", ErrInvalidOptions, FileParamMinSize, FileParamMatch, FileParamSHA256, FileParamType, FileTypeDir)
+	}
+	return nil
+}
+
+func (fw *fileWaiter) checkOnce() error {
+	log := logger.Function("checkOnce").
+		Field("waiter", "FileWaiter").
+		Field("path", fw.path)
+	info, err := os.Stat(fw.path)
+	if err != nil {
+		log.Err(err).
+			Error("unable to stat path")
+		return err
+	}
+	if info.IsDir() {
+		return fw.checkDir(info)
+	}
+	return fw.checkFile(info)
+}
+
+// checkDir checks a path which is a directory
+func (fw *fileWaiter) checkDir(info os.FileInfo) error {
+	log := logger.Function("checkDir").
+		Field("waiter", "FileWaiter").
+		Field("path", fw.path)
+	if fw.fileType == FileTypeFile || fw.minSize > 0 || fw.match != nil || fw.sha256 != "" {
+		log.Error("path is a directory")
+		return ErrFileType
+	}
+	if fw.nonEmpty {
+		dir, err := os.Open(fw.path)
+		if err != nil {
+			log.Err(err).
+				Error("unable to open directory")
+			return err
+		}
+		defer func() {
+			err = dir.Close()
+			if err != nil {
+				log.Err(err).
+					Error("error closing directory")
+			}
+		}()
+		_, err = dir.Readdirnames(1)
+		if errors.Is(err, io.EOF) {
+			log.Error("directory is empty")
+			return ErrFileEmpty
+		}
+		if err != nil {
+			log.Err(err).
+				Error("unable to read directory")
+			return err
+		}
+	}
+	log.Field("mode", info.Mode().String()).
+		Info("directory found")
+	return nil
+}
+
+// checkFile checks a path which is not a directory
+func (fw *fileWaiter) checkFile(info os.FileInfo) error {
+	log := logger.Function("checkFile").
+		Field("waiter", "FileWaiter").
+		Field("path", fw.path)
+	if fw.fileType == FileTypeDir {
+		log.Error("path is not a directory")
+		return ErrFileType
+	}
+	if fw.fileType == FileTypeFile && !info.Mode().IsRegular() {
+		log.Field("mode", info.Mode().String()).
+			Error("path is not a regular file")
+		return ErrFileType
+	}
+	if fw.nonEmpty && info.Size() == 0 {
+		log.Error("file is empty")
+		return ErrFileEmpty
+	}
+	if info.Size() < fw.minSize {
+		log.Fields(map[string]interface{}{
+			"size":    info.Size(),
+			"minSize": fw.minSize,
+		}).
+			Error("file is smaller than the minimum size")
+		return ErrFileTooSmall
+	}
+	if fw.match != nil || fw.sha256 != "" {
+		content, err := os.ReadFile(fw.path)
+		if err != nil {
+			log.Err(err).
+				Error("unable to read file")
+			return err
+		}
+		if fw.match != nil && !fw.match.Match(content) {
+			log.Field("match", fw.match.String()).
+				Error("file content did not match the expected pattern")
+			return ErrFileNoMatch
+		}
+		if fw.sha256 != "" {
+			sum := sha256.Sum256(content)
+			if hex.EncodeToString(sum[:]) != fw.sha256 {
+				log.Fields(map[string]interface{}{
+					"sha256":   hex.EncodeToString(sum[:]),
+					"expected": fw.sha256,
+				}).
+					Error("file checksum did not match")
+				return ErrFileChecksum
+			}
+		}
+	}
+	log.Field("size", info.Size()).
+		Info("file found")
+	return nil
+}
+
+func (fw *fileWaiter) delayOnce() {
+	log := logger.Function("delayOnce").
+		Field("waiter", "FileWaiter")
+	log.Field("delay", fw.retryDelay.String()).
+		Info("delaying until next attempt")
+	<-fw.ticker.C
+}
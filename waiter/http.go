@@ -1,44 +1,75 @@
 package waiter
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/neflyte/gowait/config"
 	"github.com/neflyte/gowait/lib/logger"
 )
 
+// url: https://host:port/path?tlsInsecure=true&tlsCA=/path/to/ca.pem
+// url: http+unix:///var/run/docker.sock?path=/_ping
+
+const (
+	HTTPParamTLSInsecure = "tlsInsecure"
+	HTTPParamTLSCA       = "tlsCA"
+)
+
+var (
+	// httpParams are the query parameters that gowait handles itself and does not send to the server
+	httpParams = []string{
+		HTTPParamTLSInsecure,
+		HTTPParamTLSCA,
+	}
+)
+
 type httpWaiter struct {
-	ticker    *time.Ticker
-	urlString string
-	attempts  int
+	ticker     *time.Ticker
+	client     *http.Client
+	requestURL url.URL
+	urlString  string
+	attempts   int
 }
 
+var _ prober = (*httpWaiter)(nil)
+
 func NewHTTPWaiter() Waiter {
 	return &httpWaiter{
-		urlString: "",
-		attempts:  0,
-		ticker:    time.NewTicker(config.RetryDelayDefault),
+		requestURL: url.URL{},
+		urlString:  "",
+		client:     http.DefaultClient,
+		attempts:   0,
+		ticker:     time.NewTicker(config.RetryDelayDefault),
 	}
 }
 
 func (hw *httpWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
 	log := logger.Function("Wait").
 		Field("waiter", "HTTPWaiter")
+	err := hw.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
 	success := false
 	startTime := time.Now()
 	log.Field("delay", retryDelay.String).
 		Info("Using retry delay")
 	hw.ticker = time.NewTicker(retryDelay)
-	hw.urlString = url.String()
 	hw.attempts = 0
 	for hw.attempts < retryLimit {
 		log.Field("url", hw.urlString).
 			Infof("[%d/%d] Connecting", hw.attempts+1, retryLimit)
-		err := hw.connectOnce(url)
+		err = hw.connectOnce(hw.requestURL)
 		hw.attempts++ // no matter what happens, we made an attempt
 		if err != nil {
 			if hw.attempts >= retryLimit {
@@ -76,6 +107,34 @@ func (hw *httpWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int
 	return nil
 }
 
+// probe makes a single attempt without retrying or delaying
+func (hw *httpWaiter) probe(url url.URL) error {
+	err := hw.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return hw.connectOnce(hw.requestURL)
+}
+
+// parseOptions builds the client and the request url from the url
+func (hw *httpWaiter) parseOptions(httpUrl url.URL) error {
+	var client *http.Client
+	var err error
+	if httpUrl.Scheme == HTTPUnixScheme {
+		hw.urlString = httpUrl.String()
+		client, hw.requestURL, err = newUnixHTTPClient(httpUrl)
+	} else {
+		client, err = newHTTPClient(httpUrl.Query())
+		hw.requestURL = httpRequestURL(httpUrl)
+		hw.urlString = hw.requestURL.String()
+	}
+	if err != nil {
+		return err
+	}
+	hw.client = client
+	return nil
+}
+
 func (hw *httpWaiter) connectOnce(httpUrl url.URL) error {
 	log := logger.Function("connectOnce").
 		Field("waiter", "HTTPWaiter")
@@ -87,7 +146,7 @@ func (hw *httpWaiter) connectOnce(httpUrl url.URL) error {
 	}
 	log.Field("httpUrl", httpUrl.String()).
 		Info("connecting")
-	res, err := http.DefaultClient.Do(req)
+	res, err := hw.client.Do(req)
 	if err != nil {
 		log.Err(err).
 			Error("error executing request")
@@ -118,3 +177,60 @@ func (hw *httpWaiter) delayOnce() {
 	log.Info("delaying until next attempt")
 	<-hw.ticker.C
 }
+
+// newHTTPClient returns an HTTP client which is configured with the TLS query parameters of a url
+func newHTTPClient(query url.Values) (*http.Client, error) {
+	tlsConfig, err := newTLSConfig(query)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{
+		Transport: transport,
+		Timeout:   protocolTimeout,
+	}, nil
+}
+
+// newTLSConfig returns a TLS client configuration built from the TLS query parameters of a url
+func newTLSConfig(query url.Values) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	rawInsecure := query.Get(HTTPParamTLSInsecure)
+	if rawInsecure != "" {
+		insecure, err := strconv.ParseBool(rawInsecure)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %s", ErrInvalidOptions, HTTPParamTLSInsecure, err.Error())
+		}
+		tlsConfig.InsecureSkipVerify = insecure
+	}
+	caFile := query.Get(HTTPParamTLSCA)
+	if caFile != "" {
+		rawCA, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %s", ErrInvalidOptions, HTTPParamTLSCA, err.Error())
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(rawCA) {
+			return nil, fmt.Errorf("%w: %s: no certificates found in %s", ErrInvalidOptions, HTTPParamTLSCA, caFile)
+		}
+	}
+	return tlsConfig, nil
+}
+
+// httpRequestURL returns the url without the query parameters that only gowait understands
+func httpRequestURL(httpUrl url.URL) url.URL {
+	query := httpUrl.Query()
+	found := false
+	for _, param := range httpParams {
+		if query.Has(param) {
+			query.Del(param)
+			found = true
+		}
+	}
+	if found {
+		httpUrl.RawQuery = query.Encode()
+	}
+	return httpUrl
+}
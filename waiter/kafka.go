@@ -20,6 +20,8 @@ type kafkaWaiter struct {
 	attempts int
 }
 
+var _ prober = (*kafkaWaiter)(nil)
+
 func NewKafkaWaiter() Waiter {
 	return &kafkaWaiter{
 		brokers:  make([]string, 0),
@@ -31,15 +33,11 @@ func NewKafkaWaiter() Waiter {
 func (kw *kafkaWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
 	log := logger.Function("Wait").
 		Field("waiter", "KafkaWaiter")
-	// start with the url hostname
-	kw.brokers = append(kw.brokers, url.Host)
-	// add any extra brokers
-	urlBrokers := url.Query().Get("urlBrokers")
-	if len(urlBrokers) > 0 {
-		toks := strings.Split(urlBrokers, ",")
-		for _, tok := range toks {
-			kw.brokers = append(kw.brokers, strings.TrimSpace(tok))
-		}
+	err := kw.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
 	}
 	success := false
 	startTime := time.Now()
@@ -50,7 +48,7 @@ func (kw *kafkaWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit in
 	for kw.attempts < retryLimit {
 		log.Field("brokers", fmt.Sprintf("%#v", kw.brokers)).
 			Infof("[%d/%d] Connecting", kw.attempts+1, retryLimit)
-		err := kw.connectOnce()
+		err = kw.connectOnce()
 		kw.attempts++ // no matter what happens, we made an attempt
 		if err != nil {
 			if kw.attempts >= retryLimit {
@@ -88,6 +86,30 @@ func (kw *kafkaWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit in
 	return nil
 }
 
+// probe makes a single attempt without retrying or delaying
+func (kw *kafkaWaiter) probe(url url.URL) error {
+	err := kw.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return kw.connectOnce()
+}
+
+// parseOptions reads the brokers from the url
+func (kw *kafkaWaiter) parseOptions(kafkaUrl url.URL) error {
+	// start with the url hostname
+	kw.brokers = []string{kafkaUrl.Host}
+	// add any extra brokers
+	urlBrokers := kafkaUrl.Query().Get("urlBrokers")
+	if len(urlBrokers) > 0 {
+		toks := strings.Split(urlBrokers, ",")
+		for _, tok := range toks {
+			kw.brokers = append(kw.brokers, strings.TrimSpace(tok))
+		}
+	}
+	return nil
+}
+
 func (kw *kafkaWaiter) connectOnce() error {
 	log := logger.Function("connectOnce").
 		Field("waiter", "KafkaWaiter")
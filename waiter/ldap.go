@@ -0,0 +1,414 @@
+package waiter
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/neflyte/gowait/config"
+	"github.com/neflyte/gowait/lib/logger"
+	"github.com/neflyte/gowait/lib/utils"
+)
+
+// url: ldap://cn=admin,dc=example,dc=org@host:389/dc=example,dc=org
+// url: ldaps://cn=admin,dc=example,dc=org@host:636/dc=example,dc=org?tlsCA=/path/to/ca.pem
+
+const (
+	ldapPort  = "389"
+	ldapsPort = "636"
+
+	// BER tags of the LDAPv3 messages gowait uses
+	berTagInteger      = 0x02
+	berTagOctetString  = 0x04
+	berTagEnumerated   = 0x0A
+	berTagBoolean      = 0x01
+	berTagSequence     = 0x30
+	ldapTagBindRequest = 0x60
+	ldapTagBindResp    = 0x61
+	ldapTagUnbind      = 0x42
+	ldapTagSearchReq   = 0x63
+	ldapTagSearchEntry = 0x64
+	ldapTagSearchDone  = 0x65
+	ldapTagSimpleAuth  = 0x80
+	ldapTagPresent     = 0x87
+
+	ldapVersion          = 3
+	ldapScopeBaseObject  = 0
+	ldapResultSuccess    = 0
+	ldapPresentAttribute = "objectClass"
+	// ldapNoAttributes asks the server not to return any attributes
+	ldapNoAttributes = "1.1"
+	// ldapMaxMessage is the largest BER element that is read; the bind and base search replies are far smaller
+	ldapMaxMessage = 256 * 1024
+)
+
+var (
+	ErrLDAPProtocol = errors.New("unexpected LDAP protocol response")
+	ErrLDAPResult   = errors.New("LDAP operation failed")
+	ErrLDAPNoEntry  = errors.New("base DN search returned no entry")
+)
+
+type ldapWaiter struct {
+	ticker     *time.Ticker
+	tlsConfig  *tls.Config
+	user       *url.Userinfo
+	host       string
+	baseDN     string
+	attempts   int
+	retryDelay time.Duration
+}
+
+var _ prober = (*ldapWaiter)(nil)
+
+func NewLDAPWaiter() Waiter {
+	return &ldapWaiter{
+		tlsConfig:  nil,
+		user:       nil,
+		host:       "",
+		baseDN:     "",
+		attempts:   0,
+		retryDelay: config.RetryDelayDefault,
+		ticker:     time.NewTicker(config.RetryDelayDefault),
+	}
+}
+
+func (lw *ldapWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
+	log := logger.Function("Wait").
+		Field("waiter", "LDAPWaiter")
+	err := lw.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
+	success := false
+	startTime := time.Now()
+	log.Field("retryDelay", retryDelay.String()).
+		Info("Using retry delay")
+	lw.ticker = time.NewTicker(retryDelay)
+	lw.retryDelay = retryDelay
+	urlStr := utils.SanitizedURLString(url)
+	lw.attempts = 0
+	for lw.attempts < retryLimit {
+		log.Field("url", urlStr).
+			Infof("[%d/%d] Connecting", lw.attempts+1, retryLimit)
+		err = lw.connectOnce()
+		lw.attempts++ // no matter what happens, we made an attempt
+		if err != nil {
+			if lw.attempts >= retryLimit {
+				log.Err(err).
+					Error("Connect error: retry limit reached; giving up")
+				break
+			}
+			log.Err(err).
+				Error("Connect error; delaying until next retry")
+			lw.delayOnce()
+			continue
+		}
+		// we're good
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    lw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Info("Successfully connected")
+		success = true
+		break
+	}
+	if !success {
+		errStr := fmt.Sprintf("Unable to connect to '%s' after %d attempts; elapsed time: %s", urlStr, lw.attempts, time.Since(startTime).String())
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    lw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Error("Unable to connect")
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// probe makes a single attempt without retrying or delaying
+func (lw *ldapWaiter) probe(url url.URL) error {
+	err := lw.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return lw.connectOnce()
+}
+
+// parseOptions reads the server address, the bind DN, the base DN and the TLS query parameters from the url
+func (lw *ldapWaiter) parseOptions(ldapUrl url.URL) error {
+	port := ldapPort
+	lw.tlsConfig = nil
+	if ldapUrl.Scheme == "ldaps" {
+		tlsConfig, err := newTLSConfig(ldapUrl.Query())
+		if err != nil {
+			return err
+		}
+		tlsConfig.ServerName = ldapUrl.Hostname()
+		lw.tlsConfig = tlsConfig
+		port = ldapsPort
+	}
+	lw.host = ldapUrl.Host
+	if ldapUrl.Port() == "" {
+		lw.host = net.JoinHostPort(ldapUrl.Hostname(), port)
+	}
+	lw.user = ldapUrl.User
+	if lw.user != nil && lw.user.Username() != "" {
+		// a simple bind with a DN and an empty password is an unauthenticated bind (RFC 4513 section 5.1.2) which
+		// servers accept without checking the DN
+		password, _ := lw.user.Password()
+		if password == "" {
+			return fmt.Errorf("%w: the bind DN has no password", ErrInvalidOptions)
+		}
+	}
+	lw.baseDN = strings.TrimPrefix(ldapUrl.Path, "/")
+	return nil
+}
+
+func (lw *ldapWaiter) connectOnce() error {
+	log := logger.Function("connectOnce").
+		Field("waiter", "LDAPWaiter").
+		Field("host", lw.host)
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: protocolTimeout}
+	if lw.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", lw.host, lw.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", lw.host)
+	}
+	if err != nil {
+		log.Err(err).
+			Error("unable to connect to directory server")
+		return err
+	}
+	defer func() {
+		err = conn.Close()
+		if err != nil {
+			log.Err(err).
+				Error("error closing connection")
+		}
+	}()
+	err = conn.SetDeadline(time.Now().Add(protocolTimeout))
+	if err != nil {
+		log.Err(err).
+			Error("error setting connection deadline")
+		return err
+	}
+	reader := bufio.NewReader(conn)
+	// simple bind; anonymous without a user
+	bindDN := ""
+	password := ""
+	if lw.user != nil {
+		bindDN = lw.user.Username()
+		password, _ = lw.user.Password()
+	}
+	bindRequest := berEncode(ldapTagBindRequest, bytes.Join([][]byte{
+		berEncode(berTagInteger, []byte{ldapVersion}),
+		berEncode(berTagOctetString, []byte(bindDN)),
+		berEncode(ldapTagSimpleAuth, []byte(password)),
+	}, nil))
+	_, err = conn.Write(ldapMessage(1, bindRequest))
+	if err != nil {
+		log.Err(err).
+			Error("error sending bind request")
+		return err
+	}
+	tag, content, err := ldapReadMessage(reader)
+	if err != nil {
+		log.Err(err).
+			Error("error reading bind response")
+		return err
+	}
+	if tag != ldapTagBindResp {
+		log.Field("tag", tag).
+			Error("unexpected response to bind request")
+		return ErrLDAPProtocol
+	}
+	err = ldapCheckResult(content)
+	if err != nil {
+		log.Err(err).
+			Field("bindDN", bindDN).
+			Error("bind failed")
+		return err
+	}
+	log.Field("bindDN", bindDN).
+		Info("bind succeeded")
+	if lw.baseDN != "" {
+		err = lw.searchBase(conn, reader)
+		if err != nil {
+			return err
+		}
+	}
+	// unbind; the server closes the connection without a response
+	_, err = conn.Write(ldapMessage(3, berEncode(ldapTagUnbind, nil)))
+	if err != nil {
+		log.Err(err).
+			Warn("error sending unbind request")
+	}
+	return nil
+}
+
+// searchBase runs a base-scope search for the base DN which must return the entry
+func (lw *ldapWaiter) searchBase(conn net.Conn, reader *bufio.Reader) error {
+	log := logger.Function("searchBase").
+		Field("waiter", "LDAPWaiter").
+		Field("baseDN", lw.baseDN)
+	searchRequest := berEncode(ldapTagSearchReq, bytes.Join([][]byte{
+		berEncode(berTagOctetString, []byte(lw.baseDN)),
+		berEncode(berTagEnumerated, []byte{ldapScopeBaseObject}),
+		berEncode(berTagEnumerated, []byte{0}), // neverDerefAliases
+		berEncode(berTagInteger, []byte{0}),    // sizeLimit
+		berEncode(berTagInteger, []byte{0}),    // timeLimit
+		berEncode(berTagBoolean, []byte{0}),    // typesOnly
+		berEncode(ldapTagPresent, []byte(ldapPresentAttribute)),
+		berEncode(berTagSequence, berEncode(berTagOctetString, []byte(ldapNoAttributes))),
+	}, nil))
+	_, err := conn.Write(ldapMessage(2, searchRequest))
+	if err != nil {
+		log.Err(err).
+			Error("error sending search request")
+		return err
+	}
+	entries := 0
+	for {
+		tag, content, err := ldapReadMessage(reader)
+		if err != nil {
+			log.Err(err).
+				Error("error reading search response")
+			return err
+		}
+		switch tag {
+		case ldapTagSearchEntry:
+			entries++
+			continue
+		case ldapTagSearchDone:
+			err = ldapCheckResult(content)
+			if err != nil {
+				log.Err(err).
+					Error("search failed")
+				return err
+			}
+			if entries == 0 {
+				log.Error("base DN search returned no entry")
+				return ErrLDAPNoEntry
+			}
+			log.Info("base DN exists")
+			return nil
+		}
+		// ignore search result references
+	}
+}
+
+func (lw *ldapWaiter) delayOnce() {
+	log := logger.Function("delayOnce").
+		Field("waiter", "LDAPWaiter")
+	log.Field("delay", lw.retryDelay.String()).
+		Info("delaying until next attempt")
+	<-lw.ticker.C
+}
+
+// ldapMessage wraps a protocol operation in an LDAPMessage envelope
+func ldapMessage(messageID byte, protocolOp []byte) []byte {
+	return berEncode(berTagSequence, append(berEncode(berTagInteger, []byte{messageID}), protocolOp...))
+}
+
+// ldapReadMessage reads an LDAPMessage and returns the tag and content of its protocol operation
+func ldapReadMessage(reader io.Reader) (byte, []byte, error) {
+	tag, message, err := berDecode(reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	if tag != berTagSequence {
+		return 0, nil, ErrLDAPProtocol
+	}
+	messageReader := bytes.NewReader(message)
+	_, _, err = berDecode(messageReader) // messageID
+	if err != nil {
+		return 0, nil, err
+	}
+	return berDecode(messageReader)
+}
+
+// ldapCheckResult returns an error unless the LDAPResult content has a success result code
+func ldapCheckResult(content []byte) error {
+	reader := bytes.NewReader(content)
+	tag, rawResultCode, err := berDecode(reader)
+	if err != nil {
+		return err
+	}
+	if tag != berTagEnumerated || len(rawResultCode) == 0 || len(rawResultCode) > 4 {
+		return ErrLDAPProtocol
+	}
+	resultCode := 0
+	for _, b := range rawResultCode {
+		resultCode = resultCode<<8 | int(b)
+	}
+	if resultCode == ldapResultSuccess {
+		return nil
+	}
+	_, _, _ = berDecode(reader) // matchedDN
+	_, diagnostic, _ := berDecode(reader)
+	return fmt.Errorf("%w: result code %d: %s", ErrLDAPResult, resultCode, string(diagnostic))
+}
+
+// berEncode encodes a BER TLV with a definite length
+func berEncode(tag byte, content []byte) []byte {
+	encoded := []byte{tag}
+	length := len(content)
+	switch {
+	case length < 0x80:
+		encoded = append(encoded, byte(length))
+	case length <= 0xFF:
+		encoded = append(encoded, 0x81, byte(length))
+	case length <= 0xFFFF:
+		encoded = append(encoded, 0x82, byte(length>>8), byte(length))
+	default:
+		encoded = append(encoded, 0x84, byte(length>>24), byte(length>>16), byte(length>>8), byte(length))
+	}
+	return append(encoded, content...)
+}
+
+// berDecode reads a BER TLV with a definite length
+func berDecode(reader io.Reader) (byte, []byte, error) {
+	header := make([]byte, 2)
+	_, err := io.ReadFull(reader, header)
+	if err != nil {
+		return 0, nil, err
+	}
+	length := int(header[1])
+	if header[1]&0x80 != 0 {
+		lengthBytes := make([]byte, header[1]&0x7F)
+		if len(lengthBytes) == 0 || len(lengthBytes) > 4 {
+			return 0, nil, ErrLDAPProtocol
+		}
+		_, err = io.ReadFull(reader, lengthBytes)
+		if err != nil {
+			return 0, nil, err
+		}
+		length = 0
+		for _, b := range lengthBytes {
+			length = length<<8 | int(b)
+		}
+	}
+	if length > ldapMaxMessage {
+		return 0, nil, fmt.Errorf("%w: element length %d exceeds %d", ErrLDAPProtocol, length, ldapMaxMessage)
+	}
+	content := make([]byte, length)
+	_, err = io.ReadFull(reader, content)
+	if err != nil {
+		return 0, nil, err
+	}
+	return header[0], content, nil
+}
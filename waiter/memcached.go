@@ -0,0 +1,218 @@
+package waiter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/neflyte/gowait/config"
+	"github.com/neflyte/gowait/lib/logger"
+	"github.com/neflyte/gowait/lib/utils"
+)
+
+// url: memcached://host:11211/?command=stats
+
+const (
+	MemcachedParamCommand = "command"
+
+	MemcachedCommandVersion = "version"
+	MemcachedCommandStats   = "stats"
+
+	memcachedPort = "11211"
+
+	memcachedVersionPrefix = "VERSION "
+	memcachedStatPrefix    = "STAT "
+	memcachedEnd           = "END"
+)
+
+var (
+	ErrMemcachedReply = errors.New("unexpected reply from memcached")
+)
+
+type memcachedWaiter struct {
+	ticker     *time.Ticker
+	host       string
+	command    string
+	attempts   int
+	retryDelay time.Duration
+}
+
+var _ prober = (*memcachedWaiter)(nil)
+
+func NewMemcachedWaiter() Waiter {
+	return &memcachedWaiter{
+		host:       "",
+		command:    MemcachedCommandVersion,
+		attempts:   0,
+		retryDelay: config.RetryDelayDefault,
+		ticker:     time.NewTicker(config.RetryDelayDefault),
+	}
+}
+
+func (mw *memcachedWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
+	log := logger.Function("Wait").
+		Field("waiter", "MemcachedWaiter")
+	err := mw.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
+	success := false
+	startTime := time.Now()
+	log.Field("retryDelay", retryDelay.String()).
+		Info("Using retry delay")
+	mw.ticker = time.NewTicker(retryDelay)
+	mw.retryDelay = retryDelay
+	urlStr := utils.SanitizedURLString(url)
+	mw.attempts = 0
+	for mw.attempts < retryLimit {
+		log.Field("url", urlStr).
+			Infof("[%d/%d] Connecting", mw.attempts+1, retryLimit)
+		err = mw.connectOnce()
+		mw.attempts++ // no matter what happens, we made an attempt
+		if err != nil {
+			if mw.attempts >= retryLimit {
+				log.Err(err).
+					Error("Connect error: retry limit reached; giving up")
+				break
+			}
+			log.Err(err).
+				Error("Connect error; delaying until next retry")
+			mw.delayOnce()
+			continue
+		}
+		// we're good
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    mw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Info("Successfully connected")
+		success = true
+		break
+	}
+	if !success {
+		errStr := fmt.Sprintf("Unable to connect to '%s' after %d attempts; elapsed time: %s", urlStr, mw.attempts, time.Since(startTime).String())
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    mw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Error("Unable to connect")
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// probe makes a single attempt without retrying or delaying
+func (mw *memcachedWaiter) probe(url url.URL) error {
+	err := mw.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return mw.connectOnce()
+}
+
+// parseOptions reads the server address and the gowait-specific query parameters from the url
+func (mw *memcachedWaiter) parseOptions(memcachedUrl url.URL) error {
+	mw.command = memcachedUrl.Query().Get(MemcachedParamCommand)
+	switch mw.command {
+	case "":
+		mw.command = MemcachedCommandVersion
+	case MemcachedCommandVersion, MemcachedCommandStats:
+	default:
+		return fmt.Errorf("%w: unknown %s '%s'", ErrInvalidOptions, MemcachedParamCommand, mw.command)
+	}
+	mw.host = memcachedUrl.Host
+	if memcachedUrl.Port() == "" {
+		mw.host = net.JoinHostPort(memcachedUrl.Hostname(), memcachedPort)
+	}
+	return nil
+}
+
+func (mw *memcachedWaiter) connectOnce() error {
+	log := logger.Function("connectOnce").
+		Field("waiter", "MemcachedWaiter").
+		Field("host", mw.host).
+		Field("command", mw.command)
+	conn, err := net.DialTimeout("tcp", mw.host, protocolTimeout)
+	if err != nil {
+		log.Err(err).
+			Error("unable to connect to tcp address")
+		return err
+	}
+	defer func() {
+		err = conn.Close()
+		if err != nil {
+			log.Err(err).
+				Error("error closing tcp connection")
+		}
+	}()
+	err = conn.SetDeadline(time.Now().Add(protocolTimeout))
+	if err != nil {
+		log.Err(err).
+			Error("error setting connection deadline")
+		return err
+	}
+	_, err = conn.Write([]byte(mw.command + "\r\n"))
+	if err != nil {
+		log.Err(err).
+			Error("error sending command")
+		return err
+	}
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		log.Err(err).
+			Error("error reading reply")
+		return err
+	}
+	line = strings.TrimSpace(line)
+	switch mw.command {
+	case MemcachedCommandVersion:
+		// VERSION <version>
+		if !strings.HasPrefix(line, memcachedVersionPrefix) {
+			log.Field("reply", line).
+				Error("unexpected reply from memcached")
+			return ErrMemcachedReply
+		}
+		log.Field("version", strings.TrimPrefix(line, memcachedVersionPrefix)).
+			Info("memcached answered")
+	case MemcachedCommandStats:
+		// STAT <name> <value> lines terminated by END
+		stats := 0
+		for strings.HasPrefix(line, memcachedStatPrefix) {
+			stats++
+			line, err = reader.ReadString('\n')
+			if err != nil {
+				log.Err(err).
+					Error("error reading reply")
+				return err
+			}
+			line = strings.TrimSpace(line)
+		}
+		if line != memcachedEnd || stats == 0 {
+			log.Field("reply", line).
+				Error("unexpected reply from memcached")
+			return ErrMemcachedReply
+		}
+		log.Field("stats", stats).
+			Info("memcached answered")
+	}
+	return nil
+}
+
+func (mw *memcachedWaiter) delayOnce() {
+	log := logger.Function("delayOnce").
+		Field("waiter", "MemcachedWaiter")
+	log.Field("delay", mw.retryDelay.String()).
+		Info("delaying until next attempt")
+	<-mw.ticker.C
+}
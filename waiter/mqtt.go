@@ -0,0 +1,281 @@
+package waiter
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/neflyte/gowait/config"
+	"github.com/neflyte/gowait/lib/logger"
+	"github.com/neflyte/gowait/lib/utils"
+)
+
+// url: mqtt://user@host:1883/
+// url: mqtts://user@host:8883/?tlsCA=/path/to/ca.pem
+
+const (
+	mqttPort  = "1883"
+	mqttsPort = "8883"
+
+	// MQTT 3.1.1 control packets
+	mqttPacketConnect    = 0x10
+	mqttPacketConnack    = 0x20
+	mqttPacketDisconnect = 0xE0
+
+	mqttProtocolName     = "MQTT"
+	mqttProtocolLevel    = 0x04
+	mqttFlagUsername     = 0x80
+	mqttFlagPassword     = 0x40
+	mqttFlagCleanSession = 0x02
+	mqttKeepAlive        = 30
+	mqttConnackAccepted  = 0x00
+)
+
+var (
+	ErrMQTTProtocol = errors.New("unexpected MQTT protocol response")
+	ErrMQTTRefused  = errors.New("broker refused the connection")
+
+	// mqttConnackReasons describes the CONNACK return codes
+	mqttConnackReasons = map[byte]string{
+		0x01: "unacceptable protocol version",
+		0x02: "identifier rejected",
+		0x03: "server unavailable",
+		0x04: "bad user name or password",
+		0x05: "not authorized",
+	}
+)
+
+type mqttWaiter struct {
+	ticker     *time.Ticker
+	tlsConfig  *tls.Config
+	user       *url.Userinfo
+	host       string
+	attempts   int
+	retryDelay time.Duration
+}
+
+var _ prober = (*mqttWaiter)(nil)
+
+func NewMQTTWaiter() Waiter {
+	return &mqttWaiter{
+		tlsConfig:  nil,
+		user:       nil,
+		host:       "",
+		attempts:   0,
+		retryDelay: config.RetryDelayDefault,
+		ticker:     time.NewTicker(config.RetryDelayDefault),
+	}
+}
+
+func (mw *mqttWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
+	log := logger.Function("Wait").
+		Field("waiter", "MQTTWaiter")
+	err := mw.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
+	success := false
+	startTime := time.Now()
+	log.Field("retryDelay", retryDelay.String()).
+		Info("Using retry delay")
+	mw.ticker = time.NewTicker(retryDelay)
+	mw.retryDelay = retryDelay
+	urlStr := utils.SanitizedURLString(url)
+	mw.attempts = 0
+	for mw.attempts < retryLimit {
+		log.Field("url", urlStr).
+			Infof("[%d/%d] Connecting", mw.attempts+1, retryLimit)
+		err = mw.connectOnce()
+		mw.attempts++ // no matter what happens, we made an attempt
+		if err != nil {
+			if mw.attempts >= retryLimit {
+				log.Err(err).
+					Error("Connect error: retry limit reached; giving up")
+				break
+			}
+			log.Err(err).
+				Error("Connect error; delaying until next retry")
+			mw.delayOnce()
+			continue
+		}
+		// we're good
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    mw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Info("Successfully connected")
+		success = true
+		break
+	}
+	if !success {
+		errStr := fmt.Sprintf("Unable to connect to '%s' after %d attempts; elapsed time: %s", urlStr, mw.attempts, time.Since(startTime).String())
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    mw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Error("Unable to connect")
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// probe makes a single attempt without retrying or delaying
+func (mw *mqttWaiter) probe(url url.URL) error {
+	err := mw.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return mw.connectOnce()
+}
+
+// parseOptions reads the broker address, the credentials and the TLS query parameters from the url
+func (mw *mqttWaiter) parseOptions(mqttUrl url.URL) error {
+	port := mqttPort
+	mw.tlsConfig = nil
+	if mqttUrl.Scheme == "mqtts" {
+		tlsConfig, err := newTLSConfig(mqttUrl.Query())
+		if err != nil {
+			return err
+		}
+		tlsConfig.ServerName = mqttUrl.Hostname()
+		mw.tlsConfig = tlsConfig
+		port = mqttsPort
+	}
+	mw.host = mqttUrl.Host
+	if mqttUrl.Port() == "" {
+		mw.host = net.JoinHostPort(mqttUrl.Hostname(), port)
+	}
+	mw.user = mqttUrl.User
+	return nil
+}
+
+func (mw *mqttWaiter) connectOnce() error {
+	log := logger.Function("connectOnce").
+		Field("waiter", "MQTTWaiter").
+		Field("host", mw.host)
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: protocolTimeout}
+	if mw.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", mw.host, mw.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", mw.host)
+	}
+	if err != nil {
+		log.Err(err).
+			Error("unable to connect to broker")
+		return err
+	}
+	defer func() {
+		err = conn.Close()
+		if err != nil {
+			log.Err(err).
+				Error("error closing connection")
+		}
+	}()
+	err = conn.SetDeadline(time.Now().Add(protocolTimeout))
+	if err != nil {
+		log.Err(err).
+			Error("error setting connection deadline")
+		return err
+	}
+	_, err = conn.Write(mw.connectPacket())
+	if err != nil {
+		log.Err(err).
+			Error("error sending CONNECT")
+		return err
+	}
+	// CONNACK: <type><remaining length = 2><acknowledge flags><return code>
+	connack := make([]byte, 4)
+	_, err = io.ReadFull(conn, connack)
+	if err != nil {
+		log.Err(err).
+			Error("error reading CONNACK")
+		return err
+	}
+	if connack[0] != mqttPacketConnack || connack[1] != 0x02 {
+		log.Field("packet", fmt.Sprintf("%x", connack)).
+			Error("unexpected response to CONNECT")
+		return ErrMQTTProtocol
+	}
+	if connack[3] != mqttConnackAccepted {
+		log.Fields(map[string]interface{}{
+			"returnCode": connack[3],
+			"reason":     mqttConnackReasons[connack[3]],
+		}).
+			Error("broker refused the connection")
+		return fmt.Errorf("%w: %s", ErrMQTTRefused, mqttConnackReasons[connack[3]])
+	}
+	log.Info("broker accepted the connection")
+	// say goodbye politely
+	_, err = conn.Write([]byte{mqttPacketDisconnect, 0x00})
+	if err != nil {
+		log.Err(err).
+			Warn("error sending DISCONNECT")
+	}
+	return nil
+}
+
+// connectPacket builds an MQTT 3.1.1 CONNECT packet with a clean session and the url credentials
+func (mw *mqttWaiter) connectPacket() []byte {
+	flags := byte(mqttFlagCleanSession)
+	payload := new(bytes.Buffer)
+	mqttWriteString(payload, fmt.Sprintf("gowait-%x", time.Now().UnixNano()))
+	if mw.user != nil {
+		flags |= mqttFlagUsername
+		mqttWriteString(payload, mw.user.Username())
+		password, ok := mw.user.Password()
+		if ok {
+			flags |= mqttFlagPassword
+			mqttWriteString(payload, password)
+		}
+	}
+	body := new(bytes.Buffer)
+	mqttWriteString(body, mqttProtocolName)
+	body.WriteByte(mqttProtocolLevel)
+	body.WriteByte(flags)
+	_ = binary.Write(body, binary.BigEndian, uint16(mqttKeepAlive))
+	body.Write(payload.Bytes())
+	packet := new(bytes.Buffer)
+	packet.WriteByte(mqttPacketConnect)
+	// remaining length is a variable byte integer
+	remaining := body.Len()
+	for {
+		encoded := byte(remaining % 128)
+		remaining /= 128
+		if remaining > 0 {
+			encoded |= 0x80
+		}
+		packet.WriteByte(encoded)
+		if remaining == 0 {
+			break
+		}
+	}
+	packet.Write(body.Bytes())
+	return packet.Bytes()
+}
+
+func (mw *mqttWaiter) delayOnce() {
+	log := logger.Function("delayOnce").
+		Field("waiter", "MQTTWaiter")
+	log.Field("delay", mw.retryDelay.String()).
+		Info("delaying until next attempt")
+	<-mw.ticker.C
+}
+
+func mqttWriteString(buf *bytes.Buffer, value string) {
+	_ = binary.Write(buf, binary.BigEndian, uint16(len(value)))
+	buf.WriteString(value)
+}
@@ -5,50 +5,135 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/neflyte/gowait/config"
 	"github.com/neflyte/gowait/lib/logger"
 	"github.com/neflyte/gowait/lib/utils"
 )
 
+// url: postgres://user@host1:port,host2:port/database?target_session_attrs=read-write&role=standby&maxLag=5s&extensions=postgis,pgcrypto&schemas=app&tables=app.users
+
 const (
-	SQLDriverName = "postgres"
+	PostgresDriverName = "postgres"
+	// SQLDriverName is the former name of PostgresDriverName.
+	//
+	// Deprecated: use PostgresDriverName.
+	SQLDriverName = PostgresDriverName
+
+	PostgresParamRole               = "role"
+	PostgresParamMaxLag             = "maxLag"
+	PostgresParamExtensions         = "extensions"
+	PostgresParamSchemas            = "schemas"
+	PostgresParamTables             = "tables"
+	PostgresParamTargetSessionAttrs = "target_session_attrs"
+	PostgresParamConnectTimeout     = "connect_timeout"
+
+	PostgresRolePrimary = "primary"
+	PostgresRoleStandby = "standby"
+
+	// target_session_attrs values, as understood by libpq
+	PostgresSessionAny           = "any"
+	PostgresSessionReadWrite     = "read-write"
+	PostgresSessionReadOnly      = "read-only"
+	PostgresSessionPrimary       = "primary"
+	PostgresSessionStandby       = "standby"
+	PostgresSessionPreferStandby = "prefer-standby"
+
+	// postgresReadOnlyQuery reports whether the session defaults to read-only transactions
+	postgresReadOnlyQuery = "SHOW transaction_read_only"
+
+	// postgresRecoveryQuery reports whether the server is a standby
+	postgresRecoveryQuery = "SELECT pg_is_in_recovery()"
+	// postgresReplayLagQuery reports the replay lag of a standby in seconds; a standby that has replayed
+	// everything it has received is not lagging, regardless of when the last transaction was replayed
+	postgresReplayLagQuery = `SELECT CASE
+    WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+    ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())
+END`
+	// postgresExtensionsQuery returns which of the named extensions are installed
+	postgresExtensionsQuery = "SELECT extname FROM pg_catalog.pg_extension WHERE extname = ANY($1)"
+	// postgresSchemasQuery returns which of the named schemas exist
+	postgresSchemasQuery = "SELECT nspname FROM pg_catalog.pg_namespace WHERE nspname = ANY($1)"
+	// postgresTablesQuery returns which of the named schema-qualified tables exist
+	postgresTablesQuery = "SELECT schemaname || '.' || tablename FROM pg_catalog.pg_tables WHERE schemaname || '.' || tablename = ANY($1)"
+
+	// postgresDefaultSchema is assumed for table names that are not schema-qualified
+	postgresDefaultSchema = "public"
+)
+
+var (
+	ErrPostgresRole     = errors.New("database server is not in the required role")
+	ErrPostgresLag      = errors.New("standby replication lag exceeds the limit")
+	ErrPostgresNoReplay = errors.New("standby has not replayed any transactions yet")
+	ErrPostgresMissing  = errors.New("required database objects do not exist")
+	ErrPostgresSession  = errors.New("database session does not have the required attributes")
+
+	// postgresParams are the query parameters that gowait handles itself and does not pass to lib/pq
+	postgresParams = []string{
+		PostgresParamRole,
+		PostgresParamMaxLag,
+		PostgresParamExtensions,
+		PostgresParamSchemas,
+		PostgresParamTables,
+		PostgresParamTargetSessionAttrs,
+	}
 )
 
 type postgresWaiter struct {
-	ticker     *time.Ticker
-	urlString  string
-	attempts   int
-	retryDelay time.Duration
+	ticker       *time.Ticker
+	hostURLs     []url.URL
+	sessionAttrs string
+	role         string
+	extensions   []string
+	schemas      []string
+	tables       []string
+	attempts     int
+	retryDelay   time.Duration
+	maxLag       time.Duration
 }
 
+var _ prober = (*postgresWaiter)(nil)
+
 func NewPostgresWaiter() Waiter {
 	return &postgresWaiter{
-		urlString:  "",
-		attempts:   0,
-		retryDelay: config.RetryDelayDefault,
-		ticker:     time.NewTicker(config.RetryDelayDefault),
+		hostURLs:     make([]url.URL, 0),
+		sessionAttrs: PostgresSessionAny,
+		role:         "",
+		extensions:   make([]string, 0),
+		schemas:      make([]string, 0),
+		tables:       make([]string, 0),
+		attempts:     0,
+		retryDelay:   config.RetryDelayDefault,
+		maxLag:       0,
+		ticker:       time.NewTicker(config.RetryDelayDefault),
 	}
 }
 
 func (pg *postgresWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
 	log := logger.Function("Wait").
 		Field("waiter", "PostgresWaiter")
+	err := pg.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
 	success := false
 	startTime := time.Now()
 	log.Field("retryDelay", retryDelay.String()).
 		Infof("Using retry delay")
 	pg.ticker = time.NewTicker(retryDelay)
 	pg.retryDelay = retryDelay
-	pg.urlString = url.String()
 	urlStr := utils.SanitizedURLString(url)
 	pg.attempts = 0
 	for pg.attempts < retryLimit {
 		log.Field("url", urlStr).
 			Infof("[%d/%d] Connecting", pg.attempts+1, retryLimit)
-		err := pg.connectOnce()
+		err = pg.connectOnce()
 		pg.attempts++ // no matter what happens, we made an attempt
 		if err != nil {
 			if pg.attempts >= retryLimit {
@@ -86,10 +171,96 @@ func (pg *postgresWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit
 	return nil
 }
 
+// probe makes a single attempt without retrying or delaying
+func (pg *postgresWaiter) probe(url url.URL) error {
+	err := pg.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return pg.connectOnce()
+}
+
+// parseOptions splits the url into one url per host and reads the gowait-specific query parameters from the url
+func (pg *postgresWaiter) parseOptions(pgUrl url.URL) error {
+	pg.hostURLs = postgresHostURLs(pgUrl)
+	query := pgUrl.Query()
+	pg.sessionAttrs = query.Get(PostgresParamTargetSessionAttrs)
+	switch pg.sessionAttrs {
+	case "":
+		pg.sessionAttrs = PostgresSessionAny
+	case PostgresSessionAny, PostgresSessionReadWrite, PostgresSessionReadOnly,
+		PostgresSessionPrimary, PostgresSessionStandby, PostgresSessionPreferStandby:
+	default:
+		return fmt.Errorf("%w: unknown %s '%s'", ErrInvalidOptions, PostgresParamTargetSessionAttrs, pg.sessionAttrs)
+	}
+	pg.role = query.Get(PostgresParamRole)
+	switch pg.role {
+	case "", PostgresRolePrimary, PostgresRoleStandby:
+	default:
+		return fmt.Errorf("%w: unknown %s '%s'", ErrInvalidOptions, PostgresParamRole, pg.role)
+	}
+	pg.maxLag = 0
+	rawMaxLag := query.Get(PostgresParamMaxLag)
+	if rawMaxLag != "" {
+		if pg.role != PostgresRoleStandby {
+			return fmt.Errorf("%w: %s requires %s=%s", ErrInvalidOptions, PostgresParamMaxLag, PostgresParamRole, PostgresRoleStandby)
+		}
+		maxLag, err := time.ParseDuration(rawMaxLag)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidOptions, PostgresParamMaxLag, err.Error())
+		}
+		pg.maxLag = maxLag
+	}
+	pg.extensions = utils.SplitList(query.Get(PostgresParamExtensions))
+	pg.schemas = utils.SplitList(query.Get(PostgresParamSchemas))
+	pg.tables = make([]string, 0)
+	for _, table := range utils.SplitList(query.Get(PostgresParamTables)) {
+		if !strings.Contains(table, ".") {
+			table = postgresDefaultSchema + "." + table
+		}
+		pg.tables = append(pg.tables, table)
+	}
+	return nil
+}
+
+// connectOnce tries each host in turn, as libpq does, until one of them satisfies the wait. With
+// target_session_attrs=prefer-standby, a first pass looks for a standby and a second pass accepts any server.
 func (pg *postgresWaiter) connectOnce() error {
 	log := logger.Function("connectOnce").
 		Field("waiter", "PostgresWaiter")
-	db, err := sql.Open(SQLDriverName, pg.urlString)
+	passes := []string{pg.sessionAttrs}
+	if pg.sessionAttrs == PostgresSessionPreferStandby {
+		passes = []string{PostgresSessionStandby, PostgresSessionAny}
+	}
+	var err error
+	for _, sessionAttrs := range passes {
+		for _, hostURL := range pg.hostURLs {
+			err = pg.connectHost(hostURL, sessionAttrs)
+			if err != nil {
+				log.Err(err).
+					Fields(map[string]interface{}{
+						"host":                 hostURL.Host,
+						"target_session_attrs": sessionAttrs,
+					}).
+					Warn("host did not satisfy the wait")
+				continue
+			}
+			log.Fields(map[string]interface{}{
+				"host":                 hostURL.Host,
+				"target_session_attrs": sessionAttrs,
+			}).
+				Info("host satisfied the wait")
+			return nil
+		}
+	}
+	return err
+}
+
+func (pg *postgresWaiter) connectHost(hostURL url.URL, sessionAttrs string) error {
+	log := logger.Function("connectHost").
+		Field("waiter", "PostgresWaiter").
+		Field("host", hostURL.Host)
+	db, err := sql.Open(PostgresDriverName, postgresConnString(hostURL))
 	if err != nil {
 		log.Err(err).
 			Error("error opening database connection")
@@ -109,10 +280,168 @@ func (pg *postgresWaiter) connectOnce() error {
 			Error("error pinging database")
 		return err
 	}
+	// check the session attributes
+	err = pg.checkSessionAttrs(db, sessionAttrs)
+	if err != nil {
+		return err
+	}
+	// check the server role if one was requested
+	if pg.role != "" {
+		err = pg.checkRole(db)
+		if err != nil {
+			return err
+		}
+	}
+	// check that the required objects exist
+	err = pg.checkCatalog(db, postgresExtensionsQuery, "extensions", pg.extensions)
+	if err != nil {
+		return err
+	}
+	err = pg.checkCatalog(db, postgresSchemasQuery, "schemas", pg.schemas)
+	if err != nil {
+		return err
+	}
+	err = pg.checkCatalog(db, postgresTablesQuery, "tables", pg.tables)
+	if err != nil {
+		return err
+	}
 	// we're good
 	return nil
 }
 
+func (pg *postgresWaiter) checkSessionAttrs(db *sql.DB, sessionAttrs string) error {
+	log := logger.Function("checkSessionAttrs").
+		Field("waiter", "PostgresWaiter").
+		Field("target_session_attrs", sessionAttrs)
+	switch sessionAttrs {
+	case PostgresSessionReadWrite, PostgresSessionReadOnly:
+		readOnly := ""
+		err := db.QueryRow(postgresReadOnlyQuery).Scan(&readOnly)
+		if err != nil {
+			log.Err(err).
+				Error("error querying transaction_read_only")
+			return err
+		}
+		if (readOnly == "on") != (sessionAttrs == PostgresSessionReadOnly) {
+			log.Field("transaction_read_only", readOnly).
+				Error("database session does not have the required attributes")
+			return ErrPostgresSession
+		}
+	case PostgresSessionPrimary, PostgresSessionStandby:
+		inRecovery := false
+		err := db.QueryRow(postgresRecoveryQuery).Scan(&inRecovery)
+		if err != nil {
+			log.Err(err).
+				Error("error querying recovery status")
+			return err
+		}
+		if inRecovery != (sessionAttrs == PostgresSessionStandby) {
+			log.Field("inRecovery", inRecovery).
+				Error("database session does not have the required attributes")
+			return ErrPostgresSession
+		}
+	}
+	return nil
+}
+
+func (pg *postgresWaiter) checkRole(db *sql.DB) error {
+	log := logger.Function("checkRole").
+		Field("waiter", "PostgresWaiter").
+		Field("role", pg.role)
+	inRecovery := false
+	err := db.QueryRow(postgresRecoveryQuery).Scan(&inRecovery)
+	if err != nil {
+		log.Err(err).
+			Error("error querying recovery status")
+		return err
+	}
+	if inRecovery != (pg.role == PostgresRoleStandby) {
+		log.Field("inRecovery", inRecovery).
+			Error("database server is not in the required role")
+		return ErrPostgresRole
+	}
+	if pg.maxLag == 0 {
+		return nil
+	}
+	lagSeconds := sql.NullFloat64{}
+	err = db.QueryRow(postgresReplayLagQuery).Scan(&lagSeconds)
+	if err != nil {
+		log.Err(err).
+			Error("error querying replication lag")
+		return err
+	}
+	if !lagSeconds.Valid {
+		log.Error("standby has not replayed any transactions yet")
+		return ErrPostgresNoReplay
+	}
+	lag := time.Duration(lagSeconds.Float64 * float64(time.Second))
+	if lag > pg.maxLag {
+		log.Fields(map[string]interface{}{
+			"lag":    lag.String(),
+			"maxLag": pg.maxLag.String(),
+		}).
+			Error("standby replication lag exceeds the limit")
+		return ErrPostgresLag
+	}
+	log.Field("lag", lag.String()).
+		Info("standby replication lag is within the limit")
+	return nil
+}
+
+// checkCatalog runs a catalog query which returns the subset of names that exist and fails if any are missing
+func (pg *postgresWaiter) checkCatalog(db *sql.DB, query string, kind string, names []string) error {
+	log := logger.Function("checkCatalog").
+		Field("waiter", "PostgresWaiter").
+		Field("kind", kind)
+	if len(names) == 0 {
+		return nil
+	}
+	rows, err := db.Query(query, pq.Array(names))
+	if err != nil {
+		log.Err(err).
+			Error("error querying catalog")
+		return err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			log.Err(err).
+				Error("error closing rows")
+		}
+	}()
+	found := make(map[string]bool)
+	for rows.Next() {
+		name := ""
+		err = rows.Scan(&name)
+		if err != nil {
+			log.Err(err).
+				Error("error scanning catalog row")
+			return err
+		}
+		found[name] = true
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Err(err).
+			Error("error reading catalog rows")
+		return err
+	}
+	missing := make([]string, 0)
+	for _, name := range names {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		log.Field("missing", strings.Join(missing, ",")).
+			Error("required database objects do not exist")
+		return fmt.Errorf("%w: %s: %s", ErrPostgresMissing, kind, strings.Join(missing, ","))
+	}
+	log.Field(kind, strings.Join(names, ",")).
+		Info("required database objects exist")
+	return nil
+}
+
 func (pg *postgresWaiter) delayOnce() {
 	log := logger.Function("delayOnce").
 		Field("waiter", "PostgresWaiter")
@@ -120,3 +449,29 @@ func (pg *postgresWaiter) delayOnce() {
 		Info("delaying until next attempt")
 	<-pg.ticker.C
 }
+
+// postgresHostURLs splits a libpq-style multi-host url into one url per host
+func postgresHostURLs(pgUrl url.URL) []url.URL {
+	hostURLs := make([]url.URL, 0)
+	for _, host := range strings.Split(pgUrl.Host, ",") {
+		hostURL := pgUrl
+		hostURL.Host = strings.TrimSpace(host)
+		hostURLs = append(hostURLs, hostURL)
+	}
+	return hostURLs
+}
+
+// postgresConnString returns the url as a connection string without the query parameters that only gowait understands
+// and with a default connect_timeout
+func postgresConnString(pgUrl url.URL) string {
+	query := pgUrl.Query()
+	for _, param := range postgresParams {
+		query.Del(param)
+	}
+	// lib/pq waits for the OS TCP timeout by default, which would hold up the next host of a multi-host url
+	if !query.Has(PostgresParamConnectTimeout) {
+		query.Set(PostgresParamConnectTimeout, strconv.Itoa(int(protocolTimeout.Seconds())))
+	}
+	pgUrl.RawQuery = query.Encode()
+	return pgUrl.String()
+}
@@ -0,0 +1,603 @@
+package waiter
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neflyte/gowait/config"
+	"github.com/neflyte/gowait/lib/logger"
+	"github.com/neflyte/gowait/lib/utils"
+)
+
+// url: prometheus://app:8080/metrics?condition=app_cache_warm{cache="users"}%20%3D%3D%201
+// url: prometheus://prometheus:9090/?source=query&condition=max(kafka_consumergroup_lag)%20%3C%20100
+
+const (
+	PrometheusParamSource    = "source"
+	PrometheusParamCondition = "condition"
+	PrometheusParamSecure    = "secure"
+
+	PrometheusSourceMetrics = "metrics"
+	PrometheusSourceQuery   = "query"
+
+	prometheusMetricsPath = "/metrics"
+	prometheusQueryPath   = "/api/v1/query"
+	// prometheusAccept asks for the text exposition format rather than protobuf
+	prometheusAccept = "text/plain;version=0.0.4"
+	// prometheusMaxLine is the longest line of the text exposition format that can be read
+	prometheusMaxLine = 1024 * 1024
+
+	prometheusResultVector = "vector"
+	prometheusResultScalar = "scalar"
+)
+
+var (
+	ErrPrometheusNoSamples = errors.New("no samples matched the condition")
+	ErrPrometheusCondition = errors.New("condition is not satisfied")
+	ErrPrometheusQuery     = errors.New("prometheus query failed")
+	ErrPrometheusFormat    = errors.New("invalid metrics text format")
+
+	// prometheusConditionRegex splits a condition into an expression, a comparison operator and a number
+	prometheusConditionRegex = regexp.MustCompile(`^\s*(.+?)\s*(==|!=|<=|>=|<|>)\s*([-+]?(?:[0-9.]+(?:[eE][-+]?[0-9]+)?|Inf|NaN))\s*$`)
+	// prometheusNameRegex matches a metric name at the start of a string
+	prometheusNameRegex = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*`)
+)
+
+// prometheusCondition is a comparison of the samples of an expression with a number
+type prometheusCondition struct {
+	expr  string
+	op    string
+	value float64
+}
+
+// prometheusMatcher is a label matcher of a series selector
+type prometheusMatcher struct {
+	re    *regexp.Regexp
+	label string
+	op    string
+	value string
+}
+
+type prometheusWaiter struct {
+	ticker     *time.Ticker
+	client     *http.Client
+	condition  *prometheusCondition
+	baseURL    url.URL
+	source     string
+	metric     string
+	matchers   []prometheusMatcher
+	attempts   int
+	retryDelay time.Duration
+}
+
+var _ prober = (*prometheusWaiter)(nil)
+
+func NewPrometheusWaiter() Waiter {
+	return &prometheusWaiter{
+		client:     http.DefaultClient,
+		condition:  nil,
+		baseURL:    url.URL{},
+		source:     PrometheusSourceMetrics,
+		metric:     "",
+		matchers:   make([]prometheusMatcher, 0),
+		attempts:   0,
+		retryDelay: config.RetryDelayDefault,
+		ticker:     time.NewTicker(config.RetryDelayDefault),
+	}
+}
+
+func (pw *prometheusWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
+	log := logger.Function("Wait").
+		Field("waiter", "PrometheusWaiter")
+	err := pw.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
+	success := false
+	startTime := time.Now()
+	log.Field("retryDelay", retryDelay.String()).
+		Info("Using retry delay")
+	pw.ticker = time.NewTicker(retryDelay)
+	pw.retryDelay = retryDelay
+	urlStr := utils.SanitizedURLString(url)
+	pw.attempts = 0
+	for pw.attempts < retryLimit {
+		log.Field("url", urlStr).
+			Infof("[%d/%d] Connecting", pw.attempts+1, retryLimit)
+		err = pw.connectOnce()
+		pw.attempts++ // no matter what happens, we made an attempt
+		if err != nil {
+			if pw.attempts >= retryLimit {
+				log.Err(err).
+					Error("Connect error: retry limit reached; giving up")
+				break
+			}
+			log.Err(err).
+				Error("Connect error; delaying until next retry")
+			pw.delayOnce()
+			continue
+		}
+		// we're good
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    pw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Info("Successfully connected")
+		success = true
+		break
+	}
+	if !success {
+		errStr := fmt.Sprintf("Unable to connect to '%s' after %d attempts; elapsed time: %s", urlStr, pw.attempts, time.Since(startTime).String())
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    pw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Error("Unable to connect")
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// probe makes a single attempt without retrying or delaying
+func (pw *prometheusWaiter) probe(url url.URL) error {
+	err := pw.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return pw.connectOnce()
+}
+
+// parseOptions builds the request url and reads the gowait-specific query parameters from the url
+func (pw *prometheusWaiter) parseOptions(promUrl url.URL) error {
+	query := promUrl.Query()
+	client, err := newHTTPClient(query)
+	if err != nil {
+		return err
+	}
+	pw.client = client
+	pw.baseURL = url.URL{
+		Scheme: "http",
+		User:   promUrl.User,
+		Host:   promUrl.Host,
+	}
+	rawSecure := query.Get(PrometheusParamSecure)
+	if rawSecure != "" {
+		secure, err := strconv.ParseBool(rawSecure)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidOptions, PrometheusParamSecure, err.Error())
+		}
+		if secure {
+			pw.baseURL.Scheme = "https"
+		}
+	}
+	pw.condition, err = parsePrometheusCondition(query.Get(PrometheusParamCondition))
+	if err != nil {
+		return err
+	}
+	pw.source = query.Get(PrometheusParamSource)
+	switch pw.source {
+	case "", PrometheusSourceMetrics:
+		pw.source = PrometheusSourceMetrics
+		pw.baseURL.Path = promUrl.Path
+		if pw.baseURL.Path == "" || pw.baseURL.Path == "/" {
+			pw.baseURL.Path = prometheusMetricsPath
+		}
+		pw.metric, pw.matchers, err = parsePrometheusSelector(pw.condition.expr)
+		if err != nil {
+			return err
+		}
+	case PrometheusSourceQuery:
+		// a url path is the prefix of a server behind a reverse proxy
+		pw.baseURL.Path = strings.TrimSuffix(promUrl.Path, "/") + prometheusQueryPath
+		pw.baseURL.RawQuery = url.Values{"query": []string{pw.condition.expr}}.Encode()
+	default:
+		return fmt.Errorf("%w: unknown %s '%s'", ErrInvalidOptions, PrometheusParamSource, pw.source)
+	}
+	return nil
+}
+
+func (pw *prometheusWaiter) connectOnce() error {
+	log := logger.Function("connectOnce").
+		Field("waiter", "PrometheusWaiter").
+		Field("source", pw.source)
+	var samples []float64
+	var err error
+	if pw.source == PrometheusSourceQuery {
+		samples, err = pw.querySamples()
+	} else {
+		samples, err = pw.scrapeSamples()
+	}
+	if err != nil {
+		return err
+	}
+	if len(samples) == 0 {
+		log.Field("expr", pw.condition.expr).
+			Error("no samples matched the condition")
+		return ErrPrometheusNoSamples
+	}
+	for _, sample := range samples {
+		if !pw.condition.holds(sample) {
+			log.Fields(map[string]interface{}{
+				"expr":   pw.condition.expr,
+				"op":     pw.condition.op,
+				"value":  pw.condition.value,
+				"sample": sample,
+			}).
+				Error("condition is not satisfied")
+			return ErrPrometheusCondition
+		}
+	}
+	log.Fields(map[string]interface{}{
+		"expr":    pw.condition.expr,
+		"samples": len(samples),
+	}).
+		Info("condition is satisfied")
+	return nil
+}
+
+// scrapeSamples reads the metrics endpoint and returns the values of the samples which match the selector
+func (pw *prometheusWaiter) scrapeSamples() ([]float64, error) {
+	log := logger.Function("scrapeSamples").
+		Field("waiter", "PrometheusWaiter")
+	req, err := http.NewRequest(http.MethodGet, pw.baseURL.String(), nil)
+	if err != nil {
+		log.Err(err).
+			Error("error creating new request")
+		return nil, err
+	}
+	req.Header.Set("Accept", prometheusAccept)
+	res, err := pw.client.Do(req)
+	if err != nil {
+		log.Err(err).
+			Error("error executing request")
+		return nil, err
+	}
+	defer func() {
+		err = res.Body.Close()
+		if err != nil {
+			log.Err(err).
+				Error("error closing response body")
+		}
+	}()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		log.Errorf("request error; code: %d, status: %s", res.StatusCode, res.Status)
+		return nil, ErrConnection
+	}
+	samples := make([]float64, 0)
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), prometheusMaxLine)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, labels, value, err := parsePrometheusSample(line)
+		if err != nil {
+			log.Err(err).
+				Field("line", line).
+				Error("unable to parse sample")
+			return nil, err
+		}
+		if name == pw.metric && prometheusLabelsMatch(labels, pw.matchers) {
+			samples = append(samples, value)
+		}
+	}
+	err = scanner.Err()
+	if err != nil {
+		log.Err(err).
+			Error("error reading metrics")
+		return nil, err
+	}
+	return samples, nil
+}
+
+// querySamples runs the expression as an instant query and returns the values of the result
+func (pw *prometheusWaiter) querySamples() ([]float64, error) {
+	log := logger.Function("querySamples").
+		Field("waiter", "PrometheusWaiter")
+	req, err := http.NewRequest(http.MethodGet, pw.baseURL.String(), nil)
+	if err != nil {
+		log.Err(err).
+			Error("error creating new request")
+		return nil, err
+	}
+	res, err := pw.client.Do(req)
+	if err != nil {
+		log.Err(err).
+			Error("error executing request")
+		return nil, err
+	}
+	defer func() {
+		err = res.Body.Close()
+		if err != nil {
+			log.Err(err).
+				Error("error closing response body")
+		}
+	}()
+	// errors in the query come back as JSON along with a non-2xx status
+	result := struct {
+		Status    string `json:"status"`
+		ErrorType string `json:"errorType"`
+		Error     string `json:"error"`
+		Data      struct {
+			ResultType string          `json:"resultType"`
+			Result     json.RawMessage `json:"result"`
+		} `json:"data"`
+	}{}
+	err = json.NewDecoder(res.Body).Decode(&result)
+	if err != nil {
+		log.Err(err).
+			Errorf("error decoding response body; code: %d, status: %s", res.StatusCode, res.Status)
+		return nil, err
+	}
+	if result.Status != "success" {
+		log.Fields(map[string]interface{}{
+			"errorType": result.ErrorType,
+			"error":     result.Error,
+		}).
+			Error("prometheus query failed")
+		return nil, ErrPrometheusQuery
+	}
+	rawValues := make([]string, 0)
+	switch result.Data.ResultType {
+	case prometheusResultVector:
+		vector := make([]struct {
+			Value [2]interface{} `json:"value"`
+		}, 0)
+		err = json.Unmarshal(result.Data.Result, &vector)
+		if err != nil {
+			log.Err(err).
+				Error("error decoding vector result")
+			return nil, err
+		}
+		for _, sample := range vector {
+			rawValues = append(rawValues, fmt.Sprint(sample.Value[1]))
+		}
+	case prometheusResultScalar:
+		scalar := [2]interface{}{}
+		err = json.Unmarshal(result.Data.Result, &scalar)
+		if err != nil {
+			log.Err(err).
+				Error("error decoding scalar result")
+			return nil, err
+		}
+		rawValues = append(rawValues, fmt.Sprint(scalar[1]))
+	default:
+		log.Field("resultType", result.Data.ResultType).
+			Error("query must return a vector or a scalar")
+		return nil, ErrPrometheusQuery
+	}
+	samples := make([]float64, 0, len(rawValues))
+	for _, rawValue := range rawValues {
+		value, err := strconv.ParseFloat(rawValue, 64)
+		if err != nil {
+			log.Err(err).
+				Field("value", rawValue).
+				Error("unable to parse sample value")
+			return nil, err
+		}
+		samples = append(samples, value)
+	}
+	return samples, nil
+}
+
+func (pw *prometheusWaiter) delayOnce() {
+	log := logger.Function("delayOnce").
+		Field("waiter", "PrometheusWaiter")
+	log.Field("delay", pw.retryDelay.String()).
+		Info("delaying until next attempt")
+	<-pw.ticker.C
+}
+
+// parsePrometheusCondition parses a condition like `app_cache_warm == 1`
+func parsePrometheusCondition(rawCondition string) (*prometheusCondition, error) {
+	if rawCondition == "" {
+		return nil, fmt.Errorf("%w: no %s in url", ErrInvalidOptions, PrometheusParamCondition)
+	}
+	parts := prometheusConditionRegex.FindStringSubmatch(rawCondition)
+	if parts == nil {
+		return nil, fmt.Errorf("%w: %s must look like '<expression> <operator> <number>'", ErrInvalidOptions, PrometheusParamCondition)
+	}
+	value, err := strconv.ParseFloat(parts[3], 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %s", ErrInvalidOptions, PrometheusParamCondition, err.Error())
+	}
+	return &prometheusCondition{
+		expr:  parts[1],
+		op:    parts[2],
+		value: value,
+	}, nil
+}
+
+// holds reports whether a sample satisfies the condition
+func (pc *prometheusCondition) holds(sample float64) bool {
+	switch pc.op {
+	case "==":
+		return sample == pc.value
+	case "!=":
+		return sample != pc.value
+	case "<":
+		return sample < pc.value
+	case "<=":
+		return sample <= pc.value
+	case ">":
+		return sample > pc.value
+	case ">=":
+		return sample >= pc.value
+	}
+	return false
+}
+
+// parsePrometheusSelector parses a series selector like `http_requests_total{code=~"2..",method!="GET"}`
+func parsePrometheusSelector(selector string) (string, []prometheusMatcher, error) {
+	name := prometheusNameRegex.FindString(selector)
+	if name == "" {
+		return "", nil, fmt.Errorf("%w: %s must start with a metric name", ErrInvalidOptions, PrometheusParamCondition)
+	}
+	rest := strings.TrimSpace(selector[len(name):])
+	if rest == "" {
+		return name, make([]prometheusMatcher, 0), nil
+	}
+	if !strings.HasPrefix(rest, "{") {
+		return "", nil, fmt.Errorf("%w: %s must be a series selector; use %s=%s for other expressions", ErrInvalidOptions, PrometheusParamCondition, PrometheusParamSource, PrometheusSourceQuery)
+	}
+	matchers, rest, err := parsePrometheusLabels(rest, true)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %s: %s", ErrInvalidOptions, PrometheusParamCondition, err.Error())
+	}
+	if strings.TrimSpace(rest) != "" {
+		return "", nil, fmt.Errorf("%w: %s: unexpected '%s' after selector", ErrInvalidOptions, PrometheusParamCondition, rest)
+	}
+	return name, matchers, nil
+}
+
+// parsePrometheusSample parses a sample line of the text exposition format like `name{label="value"} 1 1700000000`
+func parsePrometheusSample(line string) (string, map[string]string, float64, error) {
+	name := prometheusNameRegex.FindString(line)
+	if name == "" {
+		return "", nil, 0, ErrPrometheusFormat
+	}
+	rest := line[len(name):]
+	labels := make(map[string]string)
+	if strings.HasPrefix(rest, "{") {
+		matchers, labelRest, err := parsePrometheusLabels(rest, false)
+		if err != nil {
+			return "", nil, 0, err
+		}
+		for _, matcher := range matchers {
+			labels[matcher.label] = matcher.value
+		}
+		rest = labelRest
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return "", nil, 0, ErrPrometheusFormat
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "", nil, 0, ErrPrometheusFormat
+	}
+	return name, labels, value, nil
+}
+
+// parsePrometheusLabels parses a `{...}` label set at the start of s and returns the rest of s; operators other than
+// `=` are only accepted in selectors
+func parsePrometheusLabels(s string, selector bool) ([]prometheusMatcher, string, error) {
+	matchers := make([]prometheusMatcher, 0)
+	if !strings.HasPrefix(s, "{") {
+		return nil, "", ErrPrometheusFormat
+	}
+	s = strings.TrimLeft(s[1:], " ")
+	for {
+		if strings.HasPrefix(s, "}") {
+			return matchers, s[1:], nil
+		}
+		label := prometheusNameRegex.FindString(s)
+		if label == "" {
+			return nil, "", ErrPrometheusFormat
+		}
+		s = strings.TrimLeft(s[len(label):], " ")
+		op := "="
+		for _, candidate := range []string{"=~", "!~", "!=", "="} {
+			if strings.HasPrefix(s, candidate) {
+				op = candidate
+				break
+			}
+		}
+		if !strings.HasPrefix(s, op) || (op != "=" && !selector) {
+			return nil, "", ErrPrometheusFormat
+		}
+		s = strings.TrimLeft(s[len(op):], " ")
+		value, rest, err := parsePrometheusLabelValue(s)
+		if err != nil {
+			return nil, "", err
+		}
+		matcher := prometheusMatcher{
+			label: label,
+			op:    op,
+			value: value,
+		}
+		if op == "=~" || op == "!~" {
+			// label regexes are fully anchored
+			matcher.re, err = regexp.Compile("^(?:" + value + ")$")
+			if err != nil {
+				return nil, "", err
+			}
+		}
+		matchers = append(matchers, matcher)
+		s = strings.TrimLeft(rest, " ")
+		if strings.HasPrefix(s, ",") {
+			s = strings.TrimLeft(s[1:], " ")
+		} else if !strings.HasPrefix(s, "}") {
+			return nil, "", ErrPrometheusFormat
+		}
+	}
+}
+
+// parsePrometheusLabelValue parses a double-quoted label value at the start of s and returns the rest of s
+func parsePrometheusLabelValue(s string) (string, string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", "", ErrPrometheusFormat
+	}
+	var value strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return value.String(), s[i+1:], nil
+		case '\\':
+			i++
+			if i >= len(s) {
+				return "", "", ErrPrometheusFormat
+			}
+			switch s[i] {
+			case 'n':
+				value.WriteByte('\n')
+			default:
+				value.WriteByte(s[i])
+			}
+		default:
+			value.WriteByte(s[i])
+		}
+	}
+	return "", "", ErrPrometheusFormat
+}
+
+// prometheusLabelsMatch reports whether the labels of a sample satisfy every matcher; a missing label is empty
+func prometheusLabelsMatch(labels map[string]string, matchers []prometheusMatcher) bool {
+	for _, matcher := range matchers {
+		value := labels[matcher.label]
+		switch matcher.op {
+		case "=":
+			if value != matcher.value {
+				return false
+			}
+		case "!=":
+			if value == matcher.value {
+				return false
+			}
+		case "=~":
+			if !matcher.re.MatchString(value) {
+				return false
+			}
+		case "!~":
+			if matcher.re.MatchString(value) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,383 @@
+package waiter
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neflyte/gowait/config"
+	"github.com/neflyte/gowait/lib/logger"
+	"github.com/neflyte/gowait/lib/utils"
+)
+
+// url: s3://accessKey@bucket/path/to/key?endpoint=http://minio:9000&region=us-east-1&pathStyle=true
+
+const (
+	S3ParamEndpoint  = "endpoint"
+	S3ParamRegion    = "region"
+	S3ParamPathStyle = "pathStyle"
+
+	s3RegionDefault = "us-east-1"
+	s3Service       = "s3"
+
+	sigV4Algorithm   = "AWS4-HMAC-SHA256"
+	sigV4DateFormat  = "20060102T150405Z"
+	sigV4ScopeSuffix = "aws4_request"
+	// sigV4EmptyHash is the hex SHA-256 of an empty payload
+	sigV4EmptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	amzDateHeader          = "X-Amz-Date"
+	amzContentSHA256Header = "X-Amz-Content-Sha256"
+)
+
+var (
+	ErrS3NoBucket = errors.New("bucket does not exist")
+	ErrS3NoObject = errors.New("object does not exist")
+)
+
+type s3Waiter struct {
+	ticker     *time.Ticker
+	client     *http.Client
+	user       *url.Userinfo
+	bucketURL  url.URL
+	objectURL  url.URL
+	bucket     string
+	key        string
+	region     string
+	attempts   int
+	retryDelay time.Duration
+}
+
+var _ prober = (*s3Waiter)(nil)
+
+func NewS3Waiter() Waiter {
+	return &s3Waiter{
+		client:     http.DefaultClient,
+		user:       nil,
+		bucketURL:  url.URL{},
+		objectURL:  url.URL{},
+		bucket:     "",
+		key:        "",
+		region:     s3RegionDefault,
+		attempts:   0,
+		retryDelay: config.RetryDelayDefault,
+		ticker:     time.NewTicker(config.RetryDelayDefault),
+	}
+}
+
+func (sw *s3Waiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
+	log := logger.Function("Wait").
+		Field("waiter", "S3Waiter")
+	err := sw.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
+	success := false
+	startTime := time.Now()
+	log.Field("retryDelay", retryDelay.String()).
+		Info("Using retry delay")
+	sw.ticker = time.NewTicker(retryDelay)
+	sw.retryDelay = retryDelay
+	urlStr := utils.SanitizedURLString(url)
+	sw.attempts = 0
+	for sw.attempts < retryLimit {
+		log.Field("url", urlStr).
+			Infof("[%d/%d] Connecting", sw.attempts+1, retryLimit)
+		err = sw.connectOnce()
+		sw.attempts++ // no matter what happens, we made an attempt
+		if err != nil {
+			if sw.attempts >= retryLimit {
+				log.Err(err).
+					Error("Connect error: retry limit reached; giving up")
+				break
+			}
+			log.Err(err).
+				Error("Connect error; delaying until next retry")
+			sw.delayOnce()
+			continue
+		}
+		// we're good
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    sw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Info("Successfully connected")
+		success = true
+		break
+	}
+	if !success {
+		errStr := fmt.Sprintf("Unable to connect to '%s' after %d attempts; elapsed time: %s", urlStr, sw.attempts, time.Since(startTime).String())
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    sw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Error("Unable to connect")
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// probe makes a single attempt without retrying or delaying
+func (sw *s3Waiter) probe(url url.URL) error {
+	err := sw.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return sw.connectOnce()
+}
+
+// parseOptions builds the bucket and object urls and reads the gowait-specific query parameters from the url.
+// A custom endpoint (e.g. MinIO) uses path-style addressing by default; AWS uses virtual-hosted-style addressing.
+func (sw *s3Waiter) parseOptions(s3Url url.URL) error {
+	query := s3Url.Query()
+	client, err := newHTTPClient(query)
+	if err != nil {
+		return err
+	}
+	sw.client = client
+	sw.user = s3Url.User
+	sw.bucket = s3Url.Host
+	if sw.bucket == "" {
+		return fmt.Errorf("%w: no bucket in url", ErrInvalidOptions)
+	}
+	sw.key = strings.TrimPrefix(s3Url.Path, "/")
+	sw.region = query.Get(S3ParamRegion)
+	if sw.region == "" {
+		sw.region = s3RegionDefault
+	}
+	endpoint := &url.URL{
+		Scheme: "https",
+		Host:   fmt.Sprintf("s3.%s.amazonaws.com", sw.region),
+	}
+	pathStyle := false
+	rawEndpoint := query.Get(S3ParamEndpoint)
+	if rawEndpoint != "" {
+		endpoint, err = url.Parse(rawEndpoint)
+		if err != nil || endpoint.Host == "" {
+			return fmt.Errorf("%w: invalid %s '%s'", ErrInvalidOptions, S3ParamEndpoint, rawEndpoint)
+		}
+		pathStyle = true
+	}
+	rawPathStyle := query.Get(S3ParamPathStyle)
+	if rawPathStyle != "" {
+		pathStyle, err = strconv.ParseBool(rawPathStyle)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidOptions, S3ParamPathStyle, err.Error())
+		}
+	}
+	sw.bucketURL = url.URL{
+		Scheme: endpoint.Scheme,
+		Host:   endpoint.Host,
+		Path:   "/",
+	}
+	sw.objectURL = sw.bucketURL
+	if pathStyle {
+		sw.bucketURL.Path = "/" + sw.bucket
+		sw.objectURL.Path = "/" + sw.bucket + "/" + sw.key
+	} else {
+		sw.bucketURL.Host = sw.bucket + "." + endpoint.Host
+		sw.objectURL.Host = sw.bucketURL.Host
+		sw.objectURL.Path = "/" + sw.key
+	}
+	return nil
+}
+
+func (sw *s3Waiter) connectOnce() error {
+	log := logger.Function("connectOnce").
+		Field("waiter", "S3Waiter").
+		Field("bucket", sw.bucket)
+	if sw.key != "" {
+		// the object is checked directly; a missing bucket is reported as a missing object
+		return sw.checkObject()
+	}
+	statusCode, err := sw.head(sw.bucketURL)
+	if err != nil {
+		log.Err(err).
+			Error("error checking bucket")
+		return err
+	}
+	if statusCode == http.StatusNotFound {
+		log.Error("bucket does not exist")
+		return ErrS3NoBucket
+	}
+	if statusCode != http.StatusOK {
+		log.Errorf("unexpected response checking bucket; code: %d", statusCode)
+		return ErrConnection
+	}
+	log.Info("bucket exists")
+	return nil
+}
+
+// checkObject requires the object with the key in the url to exist; it needs only read access to the object, not
+// to the bucket
+func (sw *s3Waiter) checkObject() error {
+	log := logger.Function("checkObject").
+		Field("waiter", "S3Waiter").
+		Field("bucket", sw.bucket)
+	statusCode, err := sw.head(sw.objectURL)
+	if err != nil {
+		log.Err(err).
+			Field("key", sw.key).
+			Error("error checking object")
+		return err
+	}
+	if statusCode == http.StatusNotFound {
+		log.Field("key", sw.key).
+			Error("object does not exist")
+		return ErrS3NoObject
+	}
+	if statusCode != http.StatusOK {
+		log.Field("key", sw.key).
+			Errorf("unexpected response checking object; code: %d", statusCode)
+		return ErrConnection
+	}
+	log.Field("key", sw.key).
+		Info("object exists")
+	return nil
+}
+
+// head sends a signed HEAD request and returns the response status code
+func (sw *s3Waiter) head(reqUrl url.URL) (int, error) {
+	log := logger.Function("head").
+		Field("waiter", "S3Waiter")
+	req, err := http.NewRequest(http.MethodHead, reqUrl.String(), nil)
+	if err != nil {
+		log.Err(err).
+			Error("error creating new request")
+		return 0, err
+	}
+	if sw.user != nil {
+		secretKey, _ := sw.user.Password()
+		signSigV4(req, sw.user.Username(), secretKey, sw.region, s3Service, time.Now())
+	}
+	res, err := sw.client.Do(req)
+	if err != nil {
+		log.Err(err).
+			Error("error executing request")
+		return 0, err
+	}
+	err = res.Body.Close()
+	if err != nil {
+		log.Err(err).
+			Error("error closing response body")
+	}
+	return res.StatusCode, nil
+}
+
+func (sw *s3Waiter) delayOnce() {
+	log := logger.Function("delayOnce").
+		Field("waiter", "S3Waiter")
+	log.Field("delay", sw.retryDelay.String()).
+		Info("delaying until next attempt")
+	<-sw.ticker.C
+}
+
+// signSigV4 adds an AWS Signature Version 4 Authorization header to a request without a body. The host header and
+// every header already set on the request are signed.
+func signSigV4(req *http.Request, accessKey string, secretKey string, region string, service string, now time.Time) {
+	amzDate := now.UTC().Format(sigV4DateFormat)
+	date := amzDate[:8]
+	req.Header.Set(amzDateHeader, amzDate)
+	req.Header.Set(amzContentSHA256Header, sigV4EmptyHash)
+	// canonical headers
+	headers := map[string]string{
+		"host": req.URL.Host,
+	}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := new(strings.Builder)
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	// canonical query string
+	query := req.URL.Query()
+	queryKeys := make([]string, 0, len(query))
+	for key := range query {
+		queryKeys = append(queryKeys, key)
+	}
+	sort.Strings(queryKeys)
+	queryParts := make([]string, 0, len(queryKeys))
+	for _, key := range queryKeys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			queryParts = append(queryParts, sigV4Escape(key, true)+"="+sigV4Escape(value, true))
+		}
+	}
+	canonicalPath := req.URL.Path
+	if canonicalPath == "" {
+		canonicalPath = "/"
+	}
+	// send the path exactly as it is signed; Go leaves characters such as + and = unescaped
+	escapedPath := sigV4Escape(canonicalPath, false)
+	req.URL.RawPath = escapedPath
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escapedPath,
+		strings.Join(queryParts, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		sigV4EmptyHash,
+	}, "\n")
+	// string to sign and signature
+	scope := strings.Join([]string{date, region, service, sigV4ScopeSuffix}, "/")
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalHash[:]),
+	}, "\n")
+	signingKey := hmacSHA256([]byte("AWS4"+secretKey), date)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, service)
+	signingKey = hmacSHA256(signingKey, sigV4ScopeSuffix)
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// sigV4Escape percent-encodes everything except the RFC 3986 unreserved characters; slashes are kept as-is unless
+// encodeSlash is set
+func sigV4Escape(value string, encodeSlash bool) string {
+	escaped := new(strings.Builder)
+	for _, b := range []byte(value) {
+		switch {
+		case b >= 'A' && b <= 'Z', b >= 'a' && b <= 'z', b >= '0' && b <= '9',
+			b == '-', b == '_', b == '.', b == '~':
+			escaped.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			escaped.WriteByte(b)
+		default:
+			escaped.WriteString(fmt.Sprintf("%%%02X", b))
+		}
+	}
+	return escaped.String()
+}
@@ -0,0 +1,269 @@
+package waiter
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neflyte/gowait/config"
+	"github.com/neflyte/gowait/lib/logger"
+	"github.com/neflyte/gowait/lib/utils"
+)
+
+// url: smtp://host:25/?starttls=true&auth=PLAIN,LOGIN
+// url: smtps://host:465/?auth=PLAIN
+
+const (
+	SMTPParamStartTLS = "starttls"
+	SMTPParamAuth     = "auth"
+
+	smtpPort  = "25"
+	smtpsPort = "465"
+
+	smtpHelloName   = "localhost"
+	smtpExtStartTLS = "STARTTLS"
+	smtpExtAuth     = "AUTH"
+)
+
+var (
+	ErrSMTPNoStartTLS = errors.New("server does not offer STARTTLS")
+	ErrSMTPNoAuth     = errors.New("server does not offer the required AUTH mechanisms")
+)
+
+type smtpWaiter struct {
+	ticker         *time.Ticker
+	tlsConfig      *tls.Config
+	host           string
+	authMechanisms []string
+	implicitTLS    bool
+	startTLS       bool
+	attempts       int
+	retryDelay     time.Duration
+}
+
+var _ prober = (*smtpWaiter)(nil)
+
+func NewSMTPWaiter() Waiter {
+	return &smtpWaiter{
+		tlsConfig:      nil,
+		host:           "",
+		authMechanisms: make([]string, 0),
+		implicitTLS:    false,
+		startTLS:       false,
+		attempts:       0,
+		retryDelay:     config.RetryDelayDefault,
+		ticker:         time.NewTicker(config.RetryDelayDefault),
+	}
+}
+
+func (sw *smtpWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
+	log := logger.Function("Wait").
+		Field("waiter", "SMTPWaiter")
+	err := sw.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
+	success := false
+	startTime := time.Now()
+	log.Field("retryDelay", retryDelay.String()).
+		Info("Using retry delay")
+	sw.ticker = time.NewTicker(retryDelay)
+	sw.retryDelay = retryDelay
+	urlStr := utils.SanitizedURLString(url)
+	sw.attempts = 0
+	for sw.attempts < retryLimit {
+		log.Field("url", urlStr).
+			Infof("[%d/%d] Connecting", sw.attempts+1, retryLimit)
+		err = sw.connectOnce()
+		sw.attempts++ // no matter what happens, we made an attempt
+		if err != nil {
+			if sw.attempts >= retryLimit {
+				log.Err(err).
+					Error("Connect error: retry limit reached; giving up")
+				break
+			}
+			log.Err(err).
+				Error("Connect error; delaying until next retry")
+			sw.delayOnce()
+			continue
+		}
+		// we're good
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    sw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Info("Successfully connected")
+		success = true
+		break
+	}
+	if !success {
+		errStr := fmt.Sprintf("Unable to connect to '%s' after %d attempts; elapsed time: %s", urlStr, sw.attempts, time.Since(startTime).String())
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    sw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Error("Unable to connect")
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// probe makes a single attempt without retrying or delaying
+func (sw *smtpWaiter) probe(url url.URL) error {
+	err := sw.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return sw.connectOnce()
+}
+
+// parseOptions reads the gowait-specific query parameters from the url
+func (sw *smtpWaiter) parseOptions(smtpUrl url.URL) error {
+	query := smtpUrl.Query()
+	tlsConfig, err := newTLSConfig(query)
+	if err != nil {
+		return err
+	}
+	tlsConfig.ServerName = smtpUrl.Hostname()
+	sw.tlsConfig = tlsConfig
+	sw.implicitTLS = smtpUrl.Scheme == "smtps"
+	port := smtpPort
+	if sw.implicitTLS {
+		port = smtpsPort
+	}
+	sw.host = smtpUrl.Host
+	if smtpUrl.Port() == "" {
+		sw.host = net.JoinHostPort(smtpUrl.Hostname(), port)
+	}
+	sw.startTLS = false
+	rawStartTLS := query.Get(SMTPParamStartTLS)
+	if rawStartTLS != "" {
+		sw.startTLS, err = strconv.ParseBool(rawStartTLS)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidOptions, SMTPParamStartTLS, err.Error())
+		}
+		if sw.startTLS && sw.implicitTLS {
+			return fmt.Errorf("%w: %s cannot be used with smtps", ErrInvalidOptions, SMTPParamStartTLS)
+		}
+	}
+	sw.authMechanisms = make([]string, 0)
+	for _, mechanism := range utils.SplitList(query.Get(SMTPParamAuth)) {
+		sw.authMechanisms = append(sw.authMechanisms, strings.ToUpper(mechanism))
+	}
+	return nil
+}
+
+func (sw *smtpWaiter) connectOnce() error {
+	log := logger.Function("connectOnce").
+		Field("waiter", "SMTPWaiter").
+		Field("host", sw.host)
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: protocolTimeout}
+	if sw.implicitTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", sw.host, sw.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", sw.host)
+	}
+	if err != nil {
+		log.Err(err).
+			Error("unable to connect to mail server")
+		return err
+	}
+	err = conn.SetDeadline(time.Now().Add(protocolTimeout))
+	if err != nil {
+		log.Err(err).
+			Error("error setting connection deadline")
+		_ = conn.Close()
+		return err
+	}
+	// NewClient reads the 220 banner
+	client, err := smtp.NewClient(conn, sw.tlsConfig.ServerName)
+	if err != nil {
+		log.Err(err).
+			Error("error reading server banner")
+		_ = conn.Close()
+		return err
+	}
+	quit := false
+	defer func() {
+		if quit {
+			// a successful QUIT already closed the connection
+			return
+		}
+		err = client.Close()
+		if err != nil {
+			log.Err(err).
+				Error("error closing smtp client")
+		}
+	}()
+	err = client.Hello(smtpHelloName)
+	if err != nil {
+		log.Err(err).
+			Error("error sending EHLO")
+		return err
+	}
+	if sw.startTLS {
+		ok, _ := client.Extension(smtpExtStartTLS)
+		if !ok {
+			log.Error("server does not offer STARTTLS")
+			return ErrSMTPNoStartTLS
+		}
+		// servers commonly only offer AUTH once the session is encrypted
+		err = client.StartTLS(sw.tlsConfig)
+		if err != nil {
+			log.Err(err).
+				Error("error starting TLS")
+			return err
+		}
+		log.Info("STARTTLS succeeded")
+	}
+	if len(sw.authMechanisms) > 0 {
+		ok, params := client.Extension(smtpExtAuth)
+		offered := make(map[string]bool)
+		for _, mechanism := range strings.Fields(params) {
+			offered[strings.ToUpper(mechanism)] = true
+		}
+		for _, mechanism := range sw.authMechanisms {
+			if !ok || !offered[mechanism] {
+				log.Fields(map[string]interface{}{
+					"required": strings.Join(sw.authMechanisms, ","),
+					"offered":  params,
+				}).
+					Error("server does not offer the required AUTH mechanisms")
+				return ErrSMTPNoAuth
+			}
+		}
+		log.Field("offered", params).
+			Info("server offers the required AUTH mechanisms")
+	}
+	err = client.Quit()
+	if err != nil {
+		// Quit only closes the connection once the server has answered; the deferred Close does it otherwise
+		log.Err(err).
+			Warn("error sending QUIT")
+		return nil
+	}
+	quit = true
+	return nil
+}
+
+func (sw *smtpWaiter) delayOnce() {
+	log := logger.Function("delayOnce").
+		Field("waiter", "SMTPWaiter")
+	log.Field("delay", sw.retryDelay.String()).
+		Info("delaying until next attempt")
+	<-sw.ticker.C
+}
@@ -0,0 +1,251 @@
+package waiter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/neflyte/gowait/config"
+	"github.com/neflyte/gowait/lib/logger"
+	"github.com/neflyte/gowait/lib/utils"
+)
+
+// url: sql+<driver>://user@host:port/database?query=SELECT+1
+// url: sql+<driver>:<driver-specific DSN>?query=SELECT+1
+
+const (
+	SQLSchemePrefix = "sql+"
+
+	SQLParamQuery = "query"
+)
+
+var (
+	ErrSQLUnknownDriver = fmt.Errorf("%w: unknown database/sql driver", ErrInvalidOptions)
+	ErrSQLNoRows        = errors.New("query returned no rows")
+)
+
+type sqlWaiter struct {
+	ticker     *time.Ticker
+	driverName string
+	dsn        string
+	query      string
+	attempts   int
+	retryDelay time.Duration
+}
+
+var _ prober = (*sqlWaiter)(nil)
+
+func NewSQLWaiter() Waiter {
+	return &sqlWaiter{
+		driverName: "",
+		dsn:        "",
+		query:      "",
+		attempts:   0,
+		retryDelay: config.RetryDelayDefault,
+		ticker:     time.NewTicker(config.RetryDelayDefault),
+	}
+}
+
+func (sw *sqlWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
+	log := logger.Function("Wait").
+		Field("waiter", "SQLWaiter")
+	err := sw.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
+	success := false
+	startTime := time.Now()
+	log.Field("retryDelay", retryDelay.String()).
+		Infof("Using retry delay")
+	sw.ticker = time.NewTicker(retryDelay)
+	sw.retryDelay = retryDelay
+	urlStr := utils.SanitizedURLString(url)
+	sw.attempts = 0
+	for sw.attempts < retryLimit {
+		log.Fields(map[string]interface{}{
+			"url":    urlStr,
+			"driver": sw.driverName,
+		}).
+			Infof("[%d/%d] Connecting", sw.attempts+1, retryLimit)
+		err = sw.connectOnce()
+		sw.attempts++ // no matter what happens, we made an attempt
+		if err != nil {
+			if sw.attempts >= retryLimit {
+				log.Err(err).
+					Error("Connect error: retry limit reached; giving up")
+				break
+			}
+			log.Err(err).
+				Error("Connect error; delaying until next retry")
+			sw.delayOnce()
+			continue
+		}
+		// we're good
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"driver":      sw.driverName,
+			"attempts":    sw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Infof("Successfully connected")
+		success = true
+		break
+	}
+	if !success {
+		errStr := fmt.Sprintf("Unable to connect to '%s' after %d attempts; elapsed time: %s", urlStr, sw.attempts, time.Since(startTime).String())
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"driver":      sw.driverName,
+			"attempts":    sw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Error("Unable to connect")
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// probe makes a single attempt without retrying or delaying
+func (sw *sqlWaiter) probe(url url.URL) error {
+	err := sw.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return sw.connectOnce()
+}
+
+// parseOptions reads the driver name, the data source name and the gowait-specific query parameters from the url
+func (sw *sqlWaiter) parseOptions(sqlUrl url.URL) error {
+	sw.driverName = strings.TrimPrefix(sqlUrl.Scheme, SQLSchemePrefix)
+	if !sqlDriverRegistered(sw.driverName) {
+		drivers := sql.Drivers()
+		sort.Strings(drivers)
+		return fmt.Errorf("%w: %s; available drivers: %s", ErrSQLUnknownDriver, sw.driverName, strings.Join(drivers, ","))
+	}
+	sw.query = sqlUrl.Query().Get(SQLParamQuery)
+	sw.dsn = sqlDataSourceName(sqlUrl, sw.driverName)
+	return nil
+}
+
+// connectOnce bounds the attempt with protocolTimeout. Not every driver gives up on a connection which is still
+// starting up when the context is done, so the check runs on its own and is abandoned if it takes too long.
+func (sw *sqlWaiter) connectOnce() error {
+	log := logger.Function("connectOnce").
+		Field("waiter", "SQLWaiter").
+		Field("driver", sw.driverName)
+	ctx, cancel := context.WithTimeout(context.Background(), protocolTimeout)
+	defer cancel()
+	result := make(chan error, 1)
+	go func() {
+		result <- sw.checkDatabase(ctx)
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		log.Err(ctx.Err()).
+			Error("database did not answer in time")
+		return ctx.Err()
+	}
+}
+
+// checkDatabase pings the database and runs the query, if there is one
+func (sw *sqlWaiter) checkDatabase(ctx context.Context) error {
+	log := logger.Function("checkDatabase").
+		Field("waiter", "SQLWaiter").
+		Field("driver", sw.driverName)
+	db, err := sql.Open(sw.driverName, sw.dsn)
+	if err != nil {
+		log.Err(err).
+			Error("error opening database connection")
+		return err
+	}
+	defer func() {
+		err = db.Close()
+		if err != nil {
+			log.Err(err).
+				Errorf("error closing database")
+		}
+	}()
+	// ping the DB
+	err = db.PingContext(ctx)
+	if err != nil {
+		log.Err(err).
+			Error("error pinging database")
+		return err
+	}
+	if sw.query == "" {
+		// we're good
+		return nil
+	}
+	// run the query; it must return at least one row
+	rows, err := db.QueryContext(ctx, sw.query)
+	if err != nil {
+		log.Err(err).
+			Error("error running query")
+		return err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			log.Err(err).
+				Error("error closing rows")
+		}
+	}()
+	if !rows.Next() {
+		err = rows.Err()
+		if err != nil {
+			log.Err(err).
+				Error("error reading query results")
+			return err
+		}
+		log.Error("query returned no rows")
+		return ErrSQLNoRows
+	}
+	log.Info("query returned results")
+	return nil
+}
+
+func (sw *sqlWaiter) delayOnce() {
+	log := logger.Function("delayOnce").
+		Field("waiter", "SQLWaiter")
+	log.Field("delay", sw.retryDelay.String()).
+		Info("delaying until next attempt")
+	<-sw.ticker.C
+}
+
+// sqlDriverRegistered reports whether a database/sql driver with the given name was compiled in
+func sqlDriverRegistered(driverName string) bool {
+	for _, driver := range sql.Drivers() {
+		if driver == driverName {
+			return true
+		}
+	}
+	return false
+}
+
+// sqlDataSourceName returns the DSN to hand to the driver without the query parameters that only gowait understands.
+// An opaque url (sql+driver:dsn) passes the DSN through as-is; otherwise the url is rewritten to use the driver's
+// own scheme.
+func sqlDataSourceName(sqlUrl url.URL, driverName string) string {
+	query := sqlUrl.Query()
+	query.Del(SQLParamQuery)
+	sqlUrl.RawQuery = query.Encode()
+	if sqlUrl.Opaque != "" {
+		if sqlUrl.RawQuery == "" {
+			return sqlUrl.Opaque
+		}
+		return sqlUrl.Opaque + "?" + sqlUrl.RawQuery
+	}
+	sqlUrl.Scheme = driverName
+	return sqlUrl.String()
+}
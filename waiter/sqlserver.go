@@ -0,0 +1,197 @@
+package waiter
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	_ "github.com/microsoft/go-mssqldb"
+	"github.com/neflyte/gowait/config"
+	"github.com/neflyte/gowait/lib/logger"
+	"github.com/neflyte/gowait/lib/utils"
+)
+
+// url: sqlserver://user@host:port?database=dbname&onlineDatabase=dbname
+
+const (
+	SQLServerDriverName = "sqlserver"
+
+	SQLServerParamOnlineDatabase = "onlineDatabase"
+
+	// sqlServerPingQuery is run once the TDS login has completed
+	sqlServerPingQuery = "SELECT 1"
+	// sqlServerStateQuery returns the state of a database
+	sqlServerStateQuery = "SELECT state_desc FROM sys.databases WHERE name = @name"
+
+	sqlServerStateOnline = "ONLINE"
+)
+
+var (
+	ErrSQLServerNoDatabase = errors.New("database does not exist")
+	ErrSQLServerNotOnline  = errors.New("database is not online")
+)
+
+type sqlServerWaiter struct {
+	ticker         *time.Ticker
+	urlString      string
+	onlineDatabase string
+	attempts       int
+	retryDelay     time.Duration
+}
+
+var _ prober = (*sqlServerWaiter)(nil)
+
+func NewSQLServerWaiter() Waiter {
+	return &sqlServerWaiter{
+		urlString:      "",
+		onlineDatabase: "",
+		attempts:       0,
+		retryDelay:     config.RetryDelayDefault,
+		ticker:         time.NewTicker(config.RetryDelayDefault),
+	}
+}
+
+func (ss *sqlServerWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
+	log := logger.Function("Wait").
+		Field("waiter", "SQLServerWaiter")
+	err := ss.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
+	success := false
+	startTime := time.Now()
+	log.Field("retryDelay", retryDelay.String()).
+		Infof("Using retry delay")
+	ss.ticker = time.NewTicker(retryDelay)
+	ss.retryDelay = retryDelay
+	urlStr := utils.SanitizedURLString(url)
+	ss.attempts = 0
+	for ss.attempts < retryLimit {
+		log.Field("url", urlStr).
+			Infof("[%d/%d] Connecting", ss.attempts+1, retryLimit)
+		err = ss.connectOnce()
+		ss.attempts++ // no matter what happens, we made an attempt
+		if err != nil {
+			if ss.attempts >= retryLimit {
+				log.Err(err).
+					Error("Connect error: retry limit reached; giving up")
+				break
+			}
+			log.Err(err).
+				Error("Connect error; delaying until next retry")
+			ss.delayOnce()
+			continue
+		}
+		// we're good
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    ss.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Infof("Successfully connected")
+		success = true
+		break
+	}
+	if !success {
+		errStr := fmt.Sprintf("Unable to connect to '%s' after %d attempts; elapsed time: %s", urlStr, ss.attempts, time.Since(startTime).String())
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    ss.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Error("Unable to connect")
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// probe makes a single attempt without retrying or delaying
+func (ss *sqlServerWaiter) probe(url url.URL) error {
+	err := ss.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return ss.connectOnce()
+}
+
+// parseOptions builds the connection string and reads the gowait-specific query parameters from the url
+func (ss *sqlServerWaiter) parseOptions(ssUrl url.URL) error {
+	ss.onlineDatabase = ssUrl.Query().Get(SQLServerParamOnlineDatabase)
+	ss.urlString = sqlServerConnString(ssUrl)
+	return nil
+}
+
+func (ss *sqlServerWaiter) connectOnce() error {
+	log := logger.Function("connectOnce").
+		Field("waiter", "SQLServerWaiter")
+	db, err := sql.Open(SQLServerDriverName, ss.urlString)
+	if err != nil {
+		log.Err(err).
+			Error("error opening database connection")
+		return err
+	}
+	defer func() {
+		err = db.Close()
+		if err != nil {
+			log.Err(err).
+				Errorf("error closing database")
+		}
+	}()
+	// log in and run a trivial query
+	one := 0
+	err = db.QueryRow(sqlServerPingQuery).Scan(&one)
+	if err != nil {
+		log.Err(err).
+			Error("error querying database")
+		return err
+	}
+	// check the database state if requested
+	if ss.onlineDatabase != "" {
+		state := ""
+		err = db.QueryRow(sqlServerStateQuery, sql.Named("name", ss.onlineDatabase)).Scan(&state)
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Field("database", ss.onlineDatabase).
+				Error("database does not exist")
+			return ErrSQLServerNoDatabase
+		}
+		if err != nil {
+			log.Err(err).
+				Error("error querying database state")
+			return err
+		}
+		if state != sqlServerStateOnline {
+			log.Fields(map[string]interface{}{
+				"database": ss.onlineDatabase,
+				"state":    state,
+			}).
+				Error("database is not online")
+			return ErrSQLServerNotOnline
+		}
+		log.Field("database", ss.onlineDatabase).
+			Info("database is online")
+	}
+	// we're good
+	return nil
+}
+
+func (ss *sqlServerWaiter) delayOnce() {
+	log := logger.Function("delayOnce").
+		Field("waiter", "SQLServerWaiter")
+	log.Field("delay", ss.retryDelay.String()).
+		Info("delaying until next attempt")
+	<-ss.ticker.C
+}
+
+// sqlServerConnString returns the url as a connection string without the query parameters that only gowait understands
+func sqlServerConnString(ssUrl url.URL) string {
+	query := ssUrl.Query()
+	query.Del(SQLServerParamOnlineDatabase)
+	ssUrl.RawQuery = query.Encode()
+	return ssUrl.String()
+}
@@ -1,25 +1,57 @@
 package waiter
 
 import (
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/url"
+	"regexp"
 	"time"
 
 	"github.com/neflyte/gowait/config"
 	"github.com/neflyte/gowait/lib/logger"
 )
 
+// url: tcp://host:port/?holdOpen=2s&expect=^SSH-2.0
+// url: tcp://host:port/?send=PING%0D%0A&expect=^%2BPONG
+
+const (
+	TCPParamHoldOpen = "holdOpen"
+	TCPParamSend     = "send"
+	TCPParamSendHex  = "sendHex"
+	TCPParamExpect   = "expect"
+
+	// TCPHoldOpenDefault is how long a connection must stay open for an attempt to succeed
+	TCPHoldOpenDefault = 1 * time.Second
+
+	// tcpMaxResponse is the maximum number of bytes that are read while looking for the expected response
+	tcpMaxResponse = 64 * 1024
+)
+
+var (
+	ErrTCPClosed  = errors.New("connection was closed by the server")
+	ErrTCPNoMatch = errors.New("response did not match the expected pattern")
+)
+
 type tcpWaiter struct {
 	ticker    *time.Ticker
+	expect    *regexp.Regexp
 	urlString string
+	payload   []byte
+	holdOpen  time.Duration
 	attempts  int
 }
 
+var _ prober = (*tcpWaiter)(nil)
+
 func NewTCPWaiter() Waiter {
 	return &tcpWaiter{
+		expect:    nil,
 		urlString: "",
+		payload:   make([]byte, 0),
+		holdOpen:  TCPHoldOpenDefault,
 		attempts:  0,
 		ticker:    time.NewTicker(config.RetryDelayDefault),
 	}
@@ -28,6 +60,12 @@ func NewTCPWaiter() Waiter {
 func (tw *tcpWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
 	log := logger.Function("Wait").
 		Field("waiter", "TCPWaiter")
+	err := tw.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
 	success := false
 	startTime := time.Now()
 	log.Field("retryDelay", retryDelay.String()).
@@ -38,7 +76,7 @@ func (tw *tcpWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int)
 	for tw.attempts < retryLimit {
 		log.Field("url", tw.urlString).
 			Infof("[%d/%d] Connecting", tw.attempts+1, retryLimit)
-		err := tw.connectOnce(url.Host)
+		err = tw.connectOnce(url.Host)
 		tw.attempts++ // no matter what happens, we made an attempt
 		if err != nil {
 			if tw.attempts >= retryLimit {
@@ -76,10 +114,57 @@ func (tw *tcpWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int)
 	return nil
 }
 
+// probe makes a single attempt without retrying or delaying
+func (tw *tcpWaiter) probe(url url.URL) error {
+	err := tw.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return tw.connectOnce(url.Host)
+}
+
+// parseOptions reads the gowait-specific query parameters from the url
+func (tw *tcpWaiter) parseOptions(tcpUrl url.URL) error {
+	query := tcpUrl.Query()
+	tw.holdOpen = TCPHoldOpenDefault
+	rawHoldOpen := query.Get(TCPParamHoldOpen)
+	if rawHoldOpen != "" {
+		holdOpen, err := time.ParseDuration(rawHoldOpen)
+		if err != nil || holdOpen < 0 {
+			return fmt.Errorf("%w: %s must be a non-negative duration", ErrInvalidOptions, TCPParamHoldOpen)
+		}
+		tw.holdOpen = holdOpen
+	}
+	tw.payload = []byte(query.Get(TCPParamSend))
+	rawHex := query.Get(TCPParamSendHex)
+	if rawHex != "" {
+		if len(tw.payload) > 0 {
+			return fmt.Errorf("%w: %s and %s cannot be used together", ErrInvalidOptions, TCPParamSend, TCPParamSendHex)
+		}
+		payload, err := hex.DecodeString(rawHex)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidOptions, TCPParamSendHex, err.Error())
+		}
+		tw.payload = payload
+	}
+	tw.expect = nil
+	rawExpect := query.Get(TCPParamExpect)
+	if rawExpect != "" {
+		expect, err := regexp.Compile(rawExpect)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidOptions, TCPParamExpect, err.Error())
+		}
+		tw.expect = expect
+	}
+	return nil
+}
+
+// connectOnce connects to the host, sends the payload and reads the expected response if there are any, and then
+// requires the connection to stay open for the hold-open duration
 func (tw *tcpWaiter) connectOnce(host string) error {
 	log := logger.Function("connectOnce").
 		Field("waiter", "TCPWaiter")
-	conn, err := net.Dial("tcp", host)
+	conn, err := net.DialTimeout("tcp", host, protocolTimeout)
 	if err != nil {
 		log.Err(err).
 			Field("host", host).
@@ -93,9 +178,103 @@ func (tw *tcpWaiter) connectOnce(host string) error {
 				Error("error closing tcp connection")
 		}
 	}()
+	if len(tw.payload) > 0 {
+		err = conn.SetWriteDeadline(time.Now().Add(protocolTimeout))
+		if err != nil {
+			log.Err(err).
+				Error("error setting connection deadline")
+			return err
+		}
+		_, err = conn.Write(tw.payload)
+		if err != nil {
+			log.Err(err).
+				Error("error sending payload")
+			return err
+		}
+	}
+	if tw.expect != nil {
+		err = tw.readExpected(conn)
+		if err != nil {
+			return err
+		}
+	}
+	if tw.holdOpen > 0 {
+		err = tw.holdConnection(conn)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// readExpected reads from the connection until the response matches the expected pattern
+func (tw *tcpWaiter) readExpected(conn net.Conn) error {
+	log := logger.Function("readExpected").
+		Field("waiter", "TCPWaiter")
+	err := conn.SetReadDeadline(time.Now().Add(protocolTimeout))
+	if err != nil {
+		log.Err(err).
+			Error("error setting connection deadline")
+		return err
+	}
+	response := make([]byte, 0)
+	buf := make([]byte, 4096)
+	for len(response) < tcpMaxResponse {
+		n, readErr := conn.Read(buf)
+		response = append(response, buf[:n]...)
+		if tw.expect.Match(response) {
+			log.Field("bytes", len(response)).
+				Info("response matched the expected pattern")
+			return nil
+		}
+		if readErr != nil {
+			log.Err(readErr).
+				Field("response", fmt.Sprintf("%q", response)).
+				Error("error reading response")
+			return readErr
+		}
+	}
+	log.Fields(map[string]interface{}{
+		"response": fmt.Sprintf("%q", response),
+		"expect":   tw.expect.String(),
+	}).
+		Error("response did not match the expected pattern")
+	return ErrTCPNoMatch
+}
+
+// holdConnection keeps the connection open for the hold-open duration and fails if the server closes it first;
+// anything the server sends in the meantime is discarded
+func (tw *tcpWaiter) holdConnection(conn net.Conn) error {
+	log := logger.Function("holdConnection").
+		Field("waiter", "TCPWaiter").
+		Field("holdOpen", tw.holdOpen.String())
+	err := conn.SetReadDeadline(time.Now().Add(tw.holdOpen))
+	if err != nil {
+		log.Err(err).
+			Error("error setting connection deadline")
+		return err
+	}
+	buf := make([]byte, 4096)
+	for {
+		_, err = conn.Read(buf)
+		if err == nil {
+			continue
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Info("connection stayed open")
+			return nil
+		}
+		if errors.Is(err, io.EOF) {
+			log.Error("connection was closed by the server")
+			return ErrTCPClosed
+		}
+		log.Err(err).
+			Error("connection failed while holding it open")
+		return err
+	}
+}
+
 func (tw *tcpWaiter) delayOnce() {
 	log := logger.Function("delayOnce").
 		Field("waiter", "TCPWaiter")
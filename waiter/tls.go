@@ -0,0 +1,231 @@
+package waiter
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/neflyte/gowait/config"
+	"github.com/neflyte/gowait/lib/logger"
+	"github.com/neflyte/gowait/lib/utils"
+)
+
+// url: tls://host:443/?san=api.example.com,www.example.com&minValidity=72h&alpn=h2
+
+const (
+	TLSParamServerName  = "serverName"
+	TLSParamSAN         = "san"
+	TLSParamMinValidity = "minValidity"
+	TLSParamALPN        = "alpn"
+
+	tlsPort = "443"
+)
+
+var (
+	ErrTLSNoCertificate = errors.New("server did not present a certificate")
+	ErrTLSSAN           = errors.New("certificate is not valid for the required name")
+	ErrTLSExpiring      = errors.New("certificate expires too soon")
+	ErrTLSALPN          = errors.New("server did not negotiate the required protocol")
+)
+
+type tlsWaiter struct {
+	ticker      *time.Ticker
+	tlsConfig   *tls.Config
+	host        string
+	alpn        string
+	sans        []string
+	minValidity time.Duration
+	attempts    int
+	retryDelay  time.Duration
+}
+
+var _ prober = (*tlsWaiter)(nil)
+
+func NewTLSWaiter() Waiter {
+	return &tlsWaiter{
+		tlsConfig:   nil,
+		host:        "",
+		alpn:        "",
+		sans:        make([]string, 0),
+		minValidity: 0,
+		attempts:    0,
+		retryDelay:  config.RetryDelayDefault,
+		ticker:      time.NewTicker(config.RetryDelayDefault),
+	}
+}
+
+func (tw *tlsWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
+	log := logger.Function("Wait").
+		Field("waiter", "TLSWaiter")
+	err := tw.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
+	success := false
+	startTime := time.Now()
+	log.Field("retryDelay", retryDelay.String()).
+		Info("Using retry delay")
+	tw.ticker = time.NewTicker(retryDelay)
+	tw.retryDelay = retryDelay
+	urlStr := utils.SanitizedURLString(url)
+	tw.attempts = 0
+	for tw.attempts < retryLimit {
+		log.Field("url", urlStr).
+			Infof("[%d/%d] Connecting", tw.attempts+1, retryLimit)
+		err = tw.connectOnce()
+		tw.attempts++ // no matter what happens, we made an attempt
+		if err != nil {
+			if tw.attempts >= retryLimit {
+				log.Err(err).
+					Error("Connect error: retry limit reached; giving up")
+				break
+			}
+			log.Err(err).
+				Error("Connect error; delaying until next retry")
+			tw.delayOnce()
+			continue
+		}
+		// we're good
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    tw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Info("Successfully connected")
+		success = true
+		break
+	}
+	if !success {
+		errStr := fmt.Sprintf("Unable to connect to '%s' after %d attempts; elapsed time: %s", urlStr, tw.attempts, time.Since(startTime).String())
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    tw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Error("Unable to connect")
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// probe makes a single attempt without retrying or delaying
+func (tw *tlsWaiter) probe(url url.URL) error {
+	err := tw.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return tw.connectOnce()
+}
+
+// parseOptions sets up the TLS configuration and reads the gowait-specific query parameters from the url
+func (tw *tlsWaiter) parseOptions(tlsUrl url.URL) error {
+	query := tlsUrl.Query()
+	tlsConfig, err := newTLSConfig(query)
+	if err != nil {
+		return err
+	}
+	tlsConfig.ServerName = tlsUrl.Hostname()
+	if query.Get(TLSParamServerName) != "" {
+		tlsConfig.ServerName = query.Get(TLSParamServerName)
+	}
+	tw.alpn = query.Get(TLSParamALPN)
+	if tw.alpn != "" {
+		tlsConfig.NextProtos = []string{tw.alpn}
+	}
+	tw.tlsConfig = tlsConfig
+	tw.sans = utils.SplitList(query.Get(TLSParamSAN))
+	tw.minValidity = 0
+	rawMinValidity := query.Get(TLSParamMinValidity)
+	if rawMinValidity != "" {
+		minValidity, err := time.ParseDuration(rawMinValidity)
+		if err != nil || minValidity < 0 {
+			return fmt.Errorf("%w: %s must be a non-negative duration", ErrInvalidOptions, TLSParamMinValidity)
+		}
+		tw.minValidity = minValidity
+	}
+	tw.host = tlsUrl.Host
+	if tlsUrl.Port() == "" {
+		tw.host = net.JoinHostPort(tlsUrl.Hostname(), tlsPort)
+	}
+	return nil
+}
+
+// connectOnce completes a TLS handshake, which verifies the certificate chain unless tlsInsecure is set, and then
+// checks the server certificate and the negotiated protocol
+func (tw *tlsWaiter) connectOnce() error {
+	log := logger.Function("connectOnce").
+		Field("waiter", "TLSWaiter").
+		Field("host", tw.host)
+	dialer := &net.Dialer{Timeout: protocolTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", tw.host, tw.tlsConfig)
+	if err != nil {
+		log.Err(err).
+			Error("unable to complete tls handshake")
+		return err
+	}
+	defer func() {
+		err = conn.Close()
+		if err != nil {
+			log.Err(err).
+				Error("error closing tls connection")
+		}
+	}()
+	state := conn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		log.Error("server did not present a certificate")
+		return ErrTLSNoCertificate
+	}
+	leaf := state.PeerCertificates[0]
+	for _, san := range tw.sans {
+		err = leaf.VerifyHostname(san)
+		if err != nil {
+			log.Err(err).
+				Fields(map[string]interface{}{
+					"san":      san,
+					"dnsNames": strings.Join(leaf.DNSNames, ","),
+				}).
+				Error("certificate is not valid for the required name")
+			return ErrTLSSAN
+		}
+	}
+	validFor := time.Until(leaf.NotAfter)
+	if validFor < tw.minValidity {
+		log.Fields(map[string]interface{}{
+			"notAfter":    leaf.NotAfter.String(),
+			"minValidity": tw.minValidity.String(),
+		}).
+			Error("certificate expires too soon")
+		return ErrTLSExpiring
+	}
+	if tw.alpn != "" && state.NegotiatedProtocol != tw.alpn {
+		log.Fields(map[string]interface{}{
+			"protocol": state.NegotiatedProtocol,
+			"alpn":     tw.alpn,
+		}).
+			Error("server did not negotiate the required protocol")
+		return ErrTLSALPN
+	}
+	log.Fields(map[string]interface{}{
+		"subject":  leaf.Subject.String(),
+		"notAfter": leaf.NotAfter.String(),
+		"protocol": state.NegotiatedProtocol,
+	}).
+		Info("tls handshake complete")
+	return nil
+}
+
+func (tw *tlsWaiter) delayOnce() {
+	log := logger.Function("delayOnce").
+		Field("waiter", "TLSWaiter")
+	log.Field("delay", tw.retryDelay.String()).
+		Info("delaying until next attempt")
+	<-tw.ticker.C
+}
@@ -0,0 +1,210 @@
+package waiter
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/neflyte/gowait/config"
+	"github.com/neflyte/gowait/lib/logger"
+	"github.com/neflyte/gowait/lib/utils"
+)
+
+// url: udp://host:port/?send=ping&expect=^pong
+// url: udp://host:port/?sendHex=0a0b0c&expect=...
+
+const (
+	UDPParamSend    = "send"
+	UDPParamSendHex = "sendHex"
+	UDPParamExpect  = "expect"
+
+	udpMaxDatagram = 65535
+)
+
+var (
+	ErrUDPNoMatch = errors.New("response did not match the expected pattern")
+)
+
+type udpWaiter struct {
+	ticker     *time.Ticker
+	expect     *regexp.Regexp
+	host       string
+	payload    []byte
+	attempts   int
+	retryDelay time.Duration
+}
+
+var _ prober = (*udpWaiter)(nil)
+
+func NewUDPWaiter() Waiter {
+	return &udpWaiter{
+		expect:     nil,
+		host:       "",
+		payload:    make([]byte, 0),
+		attempts:   0,
+		retryDelay: config.RetryDelayDefault,
+		ticker:     time.NewTicker(config.RetryDelayDefault),
+	}
+}
+
+func (uw *udpWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
+	log := logger.Function("Wait").
+		Field("waiter", "UDPWaiter")
+	err := uw.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
+	success := false
+	startTime := time.Now()
+	log.Field("retryDelay", retryDelay.String()).
+		Info("Using retry delay")
+	uw.ticker = time.NewTicker(retryDelay)
+	uw.retryDelay = retryDelay
+	urlStr := utils.SanitizedURLString(url)
+	uw.attempts = 0
+	for uw.attempts < retryLimit {
+		log.Field("url", urlStr).
+			Infof("[%d/%d] Connecting", uw.attempts+1, retryLimit)
+		err = uw.connectOnce()
+		uw.attempts++ // no matter what happens, we made an attempt
+		if err != nil {
+			if uw.attempts >= retryLimit {
+				log.Err(err).
+					Error("Connect error: retry limit reached; giving up")
+				break
+			}
+			log.Err(err).
+				Error("Connect error; delaying until next retry")
+			uw.delayOnce()
+			continue
+		}
+		// we're good
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    uw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Info("Successfully connected")
+		success = true
+		break
+	}
+	if !success {
+		errStr := fmt.Sprintf("Unable to connect to '%s' after %d attempts; elapsed time: %s", urlStr, uw.attempts, time.Since(startTime).String())
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    uw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Error("Unable to connect")
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// probe makes a single attempt without retrying or delaying
+func (uw *udpWaiter) probe(url url.URL) error {
+	err := uw.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return uw.connectOnce()
+}
+
+// parseOptions reads the gowait-specific query parameters from the url
+func (uw *udpWaiter) parseOptions(udpUrl url.URL) error {
+	query := udpUrl.Query()
+	uw.host = udpUrl.Host
+	if udpUrl.Port() == "" {
+		return fmt.Errorf("%w: no port in url", ErrInvalidOptions)
+	}
+	uw.payload = []byte(query.Get(UDPParamSend))
+	rawHex := query.Get(UDPParamSendHex)
+	if rawHex != "" {
+		if len(uw.payload) > 0 {
+			return fmt.Errorf("%w: %s and %s cannot be used together", ErrInvalidOptions, UDPParamSend, UDPParamSendHex)
+		}
+		payload, err := hex.DecodeString(rawHex)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidOptions, UDPParamSendHex, err.Error())
+		}
+		uw.payload = payload
+	}
+	uw.expect = nil
+	rawExpect := query.Get(UDPParamExpect)
+	if rawExpect != "" {
+		expect, err := regexp.Compile(rawExpect)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidOptions, UDPParamExpect, err.Error())
+		}
+		uw.expect = expect
+	}
+	return nil
+}
+
+// connectOnce sends the payload and waits for a response datagram; a closed port usually surfaces as a read error
+// caused by an ICMP port unreachable message
+func (uw *udpWaiter) connectOnce() error {
+	log := logger.Function("connectOnce").
+		Field("waiter", "UDPWaiter").
+		Field("host", uw.host)
+	conn, err := net.DialTimeout("udp", uw.host, protocolTimeout)
+	if err != nil {
+		log.Err(err).
+			Error("unable to connect to udp address")
+		return err
+	}
+	defer func() {
+		err = conn.Close()
+		if err != nil {
+			log.Err(err).
+				Error("error closing udp connection")
+		}
+	}()
+	err = conn.SetDeadline(time.Now().Add(protocolTimeout))
+	if err != nil {
+		log.Err(err).
+			Error("error setting connection deadline")
+		return err
+	}
+	_, err = conn.Write(uw.payload)
+	if err != nil {
+		log.Err(err).
+			Error("error sending payload")
+		return err
+	}
+	response := make([]byte, udpMaxDatagram)
+	n, err := conn.Read(response)
+	if err != nil {
+		log.Err(err).
+			Error("error reading response")
+		return err
+	}
+	response = response[:n]
+	if uw.expect != nil && !uw.expect.Match(response) {
+		log.Fields(map[string]interface{}{
+			"response": fmt.Sprintf("%q", response),
+			"expect":   uw.expect.String(),
+		}).
+			Error("response did not match the expected pattern")
+		return ErrUDPNoMatch
+	}
+	log.Field("bytes", n).
+		Info("received response")
+	return nil
+}
+
+func (uw *udpWaiter) delayOnce() {
+	log := logger.Function("delayOnce").
+		Field("waiter", "UDPWaiter")
+	log.Field("delay", uw.retryDelay.String()).
+		Info("delaying until next attempt")
+	<-uw.ticker.C
+}
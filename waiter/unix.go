@@ -0,0 +1,196 @@
+package waiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/neflyte/gowait/config"
+	"github.com/neflyte/gowait/lib/logger"
+	"github.com/neflyte/gowait/lib/utils"
+)
+
+// url: unix:///var/run/agent.sock?type=datagram
+// url: http+unix:///var/run/docker.sock?path=/_ping
+
+const (
+	UnixParamType = "type"
+	UnixParamPath = "path"
+
+	UnixTypeStream   = "stream"
+	UnixTypeDatagram = "datagram"
+
+	// HTTPUnixScheme is the scheme of an HTTP request sent over a Unix domain socket
+	HTTPUnixScheme = "http+unix"
+
+	// unixHTTPHost is the Host header sent with HTTP requests over a Unix domain socket
+	unixHTTPHost = "localhost"
+)
+
+type unixWaiter struct {
+	ticker     *time.Ticker
+	socketPath string
+	network    string
+	attempts   int
+	retryDelay time.Duration
+}
+
+var _ prober = (*unixWaiter)(nil)
+
+func NewUnixWaiter() Waiter {
+	return &unixWaiter{
+		socketPath: "",
+		network:    "unix",
+		attempts:   0,
+		retryDelay: config.RetryDelayDefault,
+		ticker:     time.NewTicker(config.RetryDelayDefault),
+	}
+}
+
+func (uw *unixWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
+	log := logger.Function("Wait").
+		Field("waiter", "UnixWaiter")
+	err := uw.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
+	success := false
+	startTime := time.Now()
+	log.Field("retryDelay", retryDelay.String()).
+		Info("Using retry delay")
+	uw.ticker = time.NewTicker(retryDelay)
+	uw.retryDelay = retryDelay
+	urlStr := utils.SanitizedURLString(url)
+	uw.attempts = 0
+	for uw.attempts < retryLimit {
+		log.Field("url", urlStr).
+			Infof("[%d/%d] Connecting", uw.attempts+1, retryLimit)
+		err = uw.connectOnce()
+		uw.attempts++ // no matter what happens, we made an attempt
+		if err != nil {
+			if uw.attempts >= retryLimit {
+				log.Err(err).
+					Error("Connect error: retry limit reached; giving up")
+				break
+			}
+			log.Err(err).
+				Error("Connect error; delaying until next retry")
+			uw.delayOnce()
+			continue
+		}
+		// we're good
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    uw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Info("Successfully connected")
+		success = true
+		break
+	}
+	if !success {
+		errStr := fmt.Sprintf("Unable to connect to '%s' after %d attempts; elapsed time: %s", urlStr, uw.attempts, time.Since(startTime).String())
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    uw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Error("Unable to connect")
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// probe makes a single attempt without retrying or delaying
+func (uw *unixWaiter) probe(url url.URL) error {
+	err := uw.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return uw.connectOnce()
+}
+
+// parseOptions reads the socket path and the gowait-specific query parameters from the url
+func (uw *unixWaiter) parseOptions(unixUrl url.URL) error {
+	uw.socketPath = unixUrl.Path
+	if uw.socketPath == "" {
+		return fmt.Errorf("%w: no socket path in url", ErrInvalidOptions)
+	}
+	switch unixUrl.Query().Get(UnixParamType) {
+	case "", UnixTypeStream:
+		uw.network = "unix"
+	case UnixTypeDatagram:
+		uw.network = "unixgram"
+	default:
+		return fmt.Errorf("%w: unknown %s '%s'", ErrInvalidOptions, UnixParamType, unixUrl.Query().Get(UnixParamType))
+	}
+	return nil
+}
+
+func (uw *unixWaiter) connectOnce() error {
+	log := logger.Function("connectOnce").
+		Field("waiter", "UnixWaiter").
+		Fields(map[string]interface{}{
+			"socket":  uw.socketPath,
+			"network": uw.network,
+		})
+	conn, err := net.DialTimeout(uw.network, uw.socketPath, protocolTimeout)
+	if err != nil {
+		log.Err(err).
+			Error("unable to connect to unix socket")
+		return err
+	}
+	defer func() {
+		err = conn.Close()
+		if err != nil {
+			log.Err(err).
+				Error("error closing unix socket connection")
+		}
+	}()
+	return nil
+}
+
+func (uw *unixWaiter) delayOnce() {
+	log := logger.Function("delayOnce").
+		Field("waiter", "UnixWaiter")
+	log.Field("delay", uw.retryDelay.String()).
+		Info("delaying until next attempt")
+	<-uw.ticker.C
+}
+
+// newUnixHTTPClient returns an HTTP client which sends every request over the socket in an http+unix url, along
+// with the url of the request to send
+func newUnixHTTPClient(unixUrl url.URL) (*http.Client, url.URL, error) {
+	socketPath := unixUrl.Path
+	if socketPath == "" {
+		return nil, url.URL{}, fmt.Errorf("%w: no socket path in url", ErrInvalidOptions)
+	}
+	query := unixUrl.Query()
+	requestUrl, err := url.Parse(query.Get(UnixParamPath))
+	if err != nil {
+		return nil, url.URL{}, fmt.Errorf("%w: %s: %s", ErrInvalidOptions, UnixParamPath, err.Error())
+	}
+	requestUrl.Scheme = "http"
+	requestUrl.Host = unixHTTPHost
+	if requestUrl.Path == "" {
+		requestUrl.Path = "/"
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, _ string, _ string) (net.Conn, error) {
+		dialer := net.Dialer{Timeout: protocolTimeout}
+		return dialer.DialContext(ctx, "unix", socketPath)
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   protocolTimeout,
+	}, *requestUrl, nil
+}
@@ -0,0 +1,209 @@
+package waiter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/neflyte/gowait/config"
+	"github.com/neflyte/gowait/lib/logger"
+	"github.com/neflyte/gowait/lib/utils"
+)
+
+// url: vault://host:8200/?secure=true
+
+const (
+	VaultParamSecure = "secure"
+
+	vaultPort       = "8200"
+	vaultHealthPath = "/v1/sys/health"
+)
+
+var (
+	ErrVaultNotInitialized = errors.New("vault is not initialized")
+	ErrVaultSealed         = errors.New("vault is sealed")
+)
+
+// vaultHealth is the subset of a /v1/sys/health response that gowait looks at
+type vaultHealth struct {
+	Version     string `json:"version"`
+	Initialized bool   `json:"initialized"`
+	Sealed      bool   `json:"sealed"`
+	Standby     bool   `json:"standby"`
+}
+
+type vaultWaiter struct {
+	ticker     *time.Ticker
+	client     *http.Client
+	healthURL  url.URL
+	attempts   int
+	retryDelay time.Duration
+}
+
+var _ prober = (*vaultWaiter)(nil)
+
+func NewVaultWaiter() Waiter {
+	return &vaultWaiter{
+		client:     http.DefaultClient,
+		healthURL:  url.URL{},
+		attempts:   0,
+		retryDelay: config.RetryDelayDefault,
+		ticker:     time.NewTicker(config.RetryDelayDefault),
+	}
+}
+
+func (vw *vaultWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
+	log := logger.Function("Wait").
+		Field("waiter", "VaultWaiter")
+	err := vw.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
+	success := false
+	startTime := time.Now()
+	log.Field("retryDelay", retryDelay.String()).
+		Info("Using retry delay")
+	vw.ticker = time.NewTicker(retryDelay)
+	vw.retryDelay = retryDelay
+	urlStr := utils.SanitizedURLString(url)
+	vw.attempts = 0
+	for vw.attempts < retryLimit {
+		log.Field("url", urlStr).
+			Infof("[%d/%d] Connecting", vw.attempts+1, retryLimit)
+		err = vw.connectOnce()
+		vw.attempts++ // no matter what happens, we made an attempt
+		if err != nil {
+			if vw.attempts >= retryLimit {
+				log.Err(err).
+					Error("Connect error: retry limit reached; giving up")
+				break
+			}
+			log.Err(err).
+				Error("Connect error; delaying until next retry")
+			vw.delayOnce()
+			continue
+		}
+		// we're good
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    vw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Info("Successfully connected")
+		success = true
+		break
+	}
+	if !success {
+		errStr := fmt.Sprintf("Unable to connect to '%s' after %d attempts; elapsed time: %s", urlStr, vw.attempts, time.Since(startTime).String())
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    vw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Error("Unable to connect")
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// probe makes a single attempt without retrying or delaying
+func (vw *vaultWaiter) probe(url url.URL) error {
+	err := vw.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return vw.connectOnce()
+}
+
+// parseOptions builds the health endpoint url from the url
+func (vw *vaultWaiter) parseOptions(vaultUrl url.URL) error {
+	query := vaultUrl.Query()
+	client, err := newHTTPClient(query)
+	if err != nil {
+		return err
+	}
+	vw.client = client
+	vw.healthURL = url.URL{
+		Scheme: "http",
+		Host:   vaultUrl.Host,
+		Path:   vaultHealthPath,
+	}
+	rawSecure := query.Get(VaultParamSecure)
+	if rawSecure != "" {
+		secure, err := strconv.ParseBool(rawSecure)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidOptions, VaultParamSecure, err.Error())
+		}
+		if secure {
+			vw.healthURL.Scheme = "https"
+		}
+	}
+	if vaultUrl.Port() == "" {
+		vw.healthURL.Host = net.JoinHostPort(vaultUrl.Hostname(), vaultPort)
+	}
+	return nil
+}
+
+func (vw *vaultWaiter) connectOnce() error {
+	log := logger.Function("connectOnce").
+		Field("waiter", "VaultWaiter")
+	req, err := http.NewRequest(http.MethodGet, vw.healthURL.String(), nil)
+	if err != nil {
+		log.Err(err).
+			Error("error creating new request")
+		return err
+	}
+	res, err := vw.client.Do(req)
+	if err != nil {
+		log.Err(err).
+			Error("error executing request")
+		return err
+	}
+	defer func() {
+		err = res.Body.Close()
+		if err != nil {
+			log.Err(err).
+				Error("error closing response body")
+		}
+	}()
+	// the status code encodes the state (e.g. 501 uninitialized, 503 sealed) but the body describes it fully,
+	// so decode the body regardless of the status
+	health := vaultHealth{}
+	err = json.NewDecoder(res.Body).Decode(&health)
+	if err != nil {
+		log.Err(err).
+			Errorf("error decoding response body; code: %d, status: %s", res.StatusCode, res.Status)
+		return err
+	}
+	if !health.Initialized {
+		log.Error("vault is not initialized")
+		return ErrVaultNotInitialized
+	}
+	if health.Sealed {
+		log.Error("vault is sealed")
+		return ErrVaultSealed
+	}
+	log.Fields(map[string]interface{}{
+		"version": health.Version,
+		"standby": health.Standby,
+	}).
+		Info("vault is initialized and unsealed")
+	return nil
+}
+
+func (vw *vaultWaiter) delayOnce() {
+	log := logger.Function("delayOnce").
+		Field("waiter", "VaultWaiter")
+	log.Field("delay", vw.retryDelay.String()).
+		Info("delaying until next attempt")
+	<-vw.ticker.C
+}
@@ -1,35 +1,240 @@
 package waiter
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
 	"time"
+
+	"github.com/neflyte/gowait/lib/logger"
+	"github.com/neflyte/gowait/lib/utils"
+)
+
+const (
+	// protocolTimeout bounds a single connection attempt and protocol exchange with a service
+	protocolTimeout = 10 * time.Second
 )
 
 var (
-	ErrConnection = errors.New("connection error")
+	ErrConnection     = errors.New("connection error")
+	ErrInvalidOptions = errors.New("invalid waiter options")
 )
 
 type Waiter interface {
 	Wait(url url.URL, retryDelay time.Duration, retryLimit int) error
 }
 
+// prober is implemented by every waiter so that WaitStable and WaitDown can run the attempts themselves; probe reads
+// the options from the url and tries the service once
+type prober interface {
+	Waiter
+	probe(url url.URL) error
+}
+
 func Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
-	// select the appropriate waiter
+	waiter, err := newWaiter(url)
+	if err != nil {
+		return err
+	}
+	// wait For IT!!
+	return waiter.Wait(url, retryDelay, retryLimit)
+}
+
+// WaitStable waits for the service at the url to be available for successThreshold consecutive attempts and for at
+// least stableFor since the first of them. Each attempt is a single try of the waiter for the url scheme, and every
+// try counts towards retryLimit.
+func WaitStable(url url.URL, retryDelay time.Duration, retryLimit int, successThreshold int, stableFor time.Duration) error {
+	return waitConsecutive(url, retryDelay, retryLimit, successThreshold, stableFor, false)
+}
+
+// WaitDown waits for the service at the url to stop being available for successThreshold consecutive attempts and
+// for at least stableFor since the first of them. Each attempt is a single try of the waiter for the url scheme which
+// succeeds when the try fails in a way that shows the service is gone (see serviceDown); a service which answers
+// with an error is still up, and invalid waiter options are still an error.
+func WaitDown(url url.URL, retryDelay time.Duration, retryLimit int, successThreshold int, stableFor time.Duration) error {
+	return waitConsecutive(url, retryDelay, retryLimit, successThreshold, stableFor, true)
+}
+
+// waitConsecutive probes the service once per attempt until it has been in the awaited state for enough consecutive
+// attempts; down inverts the result of each probe
+func waitConsecutive(url url.URL, retryDelay time.Duration, retryLimit int, successThreshold int, stableFor time.Duration, down bool) error {
+	log := logger.Function("waitConsecutive").
+		Fields(map[string]interface{}{
+			"down":             down,
+			"successThreshold": successThreshold,
+			"stableFor":        stableFor.String(),
+		})
+	waiter, err := newWaiter(url)
+	if err != nil {
+		return err
+	}
+	state := "up"
+	if down {
+		state = "down"
+	}
+	startTime := time.Now()
+	stableSince := time.Time{}
+	ticker := time.NewTicker(retryDelay)
+	defer ticker.Stop()
+	urlStr := utils.SanitizedURLString(url)
+	attempts := 0
+	successes := 0
+	for attempts < retryLimit {
+		log.Field("url", urlStr).
+			Infof("[%d/%d] Checking that the service is %s", attempts+1, retryLimit, state)
+		err = waiter.probe(url)
+		attempts++
+		if errors.Is(err, ErrInvalidOptions) {
+			log.Err(err).
+				Error("unable to parse waiter options from url")
+			return err
+		}
+		reached := err == nil
+		if down {
+			reached = err != nil && serviceDown(err)
+		}
+		switch {
+		case err == nil:
+		case down && !reached:
+			log.Err(err).
+				Info("Service answered with an error; it is not down")
+		default:
+			log.Err(err).
+				Info("Service is not available")
+		}
+		if !reached {
+			if successes > 0 {
+				log.Field("successes", successes).
+					Warnf("Service is no longer %s; starting over", state)
+			}
+			successes = 0
+		} else {
+			if successes == 0 {
+				stableSince = time.Now()
+			}
+			successes++
+			if successes >= successThreshold && time.Since(stableSince) >= stableFor {
+				log.Fields(map[string]interface{}{
+					"url":         urlStr,
+					"attempts":    attempts,
+					"retryLimit":  retryLimit,
+					"successes":   successes,
+					"elapsedTime": time.Since(startTime).String(),
+				}).
+					Infof("Service is %s", state)
+				return nil
+			}
+			log.Fields(map[string]interface{}{
+				"successes":  successes,
+				"stableTime": time.Since(stableSince).String(),
+			}).
+				Infof("Service is %s; waiting for it to stay %s", state, state)
+		}
+		if attempts >= retryLimit {
+			break
+		}
+		log.Field("delay", retryDelay.String()).
+			Info("delaying until next attempt")
+		<-ticker.C
+	}
+	errStr := fmt.Sprintf("Service at '%s' was not stably %s after %d attempts; elapsed time: %s", urlStr, state, attempts, time.Since(startTime).String())
+	log.Fields(map[string]interface{}{
+		"url":         urlStr,
+		"attempts":    attempts,
+		"retryLimit":  retryLimit,
+		"elapsedTime": time.Since(startTime).String(),
+	}).
+		Errorf("Service was not stably %s", state)
+	return errors.New(errStr)
+}
+
+// serviceDown reports whether a failed attempt shows that the service is gone rather than failing: the connection
+// was refused or timed out, the host name, file or socket does not exist, or the command exited with an error
+func serviceDown(err error) bool {
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+	case errors.As(err, &netErr) && netErr.Timeout():
+	case errors.Is(err, syscall.ECONNREFUSED):
+	case errors.Is(err, context.DeadlineExceeded):
+	case errors.Is(err, os.ErrNotExist):
+	case errors.As(err, &exitErr):
+	default:
+		return false
+	}
+	return true
+}
+
+// newWaiter returns the waiter for the url scheme
+func newWaiter(url url.URL) (prober, error) {
 	var waiter Waiter
 	switch url.Scheme {
 	case "postgres":
 		waiter = NewPostgresWaiter()
 	case "tcp":
 		waiter = NewTCPWaiter()
-	case "http":
+	case "http", "https", HTTPUnixScheme:
 		waiter = NewHTTPWaiter()
 	case "kafka":
 		waiter = NewKafkaWaiter()
+	case "sqlserver":
+		waiter = NewSQLServerWaiter()
+	case "clickhouse":
+		waiter = NewClickHouseWaiter()
+	case "cql":
+		waiter = NewCQLWaiter()
+	case "elasticsearch", "opensearch":
+		waiter = NewElasticsearchWaiter()
+	case "etcd":
+		waiter = NewEtcdWaiter()
+	case "zk":
+		waiter = NewZooKeeperWaiter()
+	case "consul":
+		waiter = NewConsulWaiter()
+	case "vault":
+		waiter = NewVaultWaiter()
+	case "s3":
+		waiter = NewS3Waiter()
+	case "memcached":
+		waiter = NewMemcachedWaiter()
+	case "mqtt", "mqtts":
+		waiter = NewMQTTWaiter()
+	case "ldap", "ldaps":
+		waiter = NewLDAPWaiter()
+	case "smtp", "smtps":
+		waiter = NewSMTPWaiter()
+	case "dns":
+		waiter = NewDNSWaiter()
+	case "udp":
+		waiter = NewUDPWaiter()
+	case "unix":
+		waiter = NewUnixWaiter()
+	case "file":
+		waiter = NewFileWaiter()
+	case "exec":
+		waiter = NewExecWaiter()
+	case "tls":
+		waiter = NewTLSWaiter()
+	case "ws", "wss":
+		waiter = NewWebSocketWaiter()
+	case "prometheus":
+		waiter = NewPrometheusWaiter()
 	default:
-		return fmt.Errorf("unknown scheme: %s", url.Scheme)
+		if !strings.HasPrefix(url.Scheme, SQLSchemePrefix) {
+			return nil, fmt.Errorf("unknown scheme: %s", url.Scheme)
+		}
+		waiter = NewSQLWaiter()
 	}
-	// wait For IT!!
-	return waiter.Wait(url, retryDelay, retryLimit)
+	// every waiter asserts that it implements prober next to its type
+	return waiter.(prober), nil
 }
@@ -0,0 +1,451 @@
+package waiter
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/neflyte/gowait/config"
+	"github.com/neflyte/gowait/lib/logger"
+	"github.com/neflyte/gowait/lib/utils"
+)
+
+// url: ws://host:port/path?send=ping&expect=^pong
+// url: wss://user@host:port/path?tlsCA=/path/to/ca.pem
+
+const (
+	WebSocketParamSend   = "send"
+	WebSocketParamExpect = "expect"
+
+	webSocketPort       = "80"
+	webSocketSecurePort = "443"
+	webSocketVersion    = "13"
+	// webSocketGUID is appended to the handshake key to compute the accept key (RFC 6455 section 1.3)
+	webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	// webSocketMaxMessage is the largest message that is read while waiting for a reply
+	webSocketMaxMessage = 1024 * 1024
+
+	webSocketOpContinuation = 0x0
+	webSocketOpText         = 0x1
+	webSocketOpBinary       = 0x2
+	webSocketOpClose        = 0x8
+	webSocketOpPing         = 0x9
+	webSocketOpPong         = 0xa
+
+	// webSocketCloseNormal is the status code sent with the close frame at the end of an attempt
+	webSocketCloseNormal = 1000
+)
+
+var (
+	ErrWebSocketUpgrade  = errors.New("server did not upgrade the connection to a websocket")
+	ErrWebSocketClosed   = errors.New("server closed the websocket")
+	ErrWebSocketFrame    = errors.New("invalid websocket frame")
+	ErrWebSocketNoMatch  = errors.New("reply did not match the expected pattern")
+	ErrWebSocketTooLarge = errors.New("websocket message is too large")
+
+	// webSocketParams are the query parameters that gowait handles itself and does not send to the server
+	webSocketParams = []string{
+		WebSocketParamSend,
+		WebSocketParamExpect,
+		HTTPParamTLSInsecure,
+		HTTPParamTLSCA,
+	}
+)
+
+type webSocketWaiter struct {
+	ticker     *time.Ticker
+	tlsConfig  *tls.Config
+	expect     *regexp.Regexp
+	requestUrl url.URL
+	host       string
+	send       string
+	attempts   int
+	retryDelay time.Duration
+}
+
+var _ prober = (*webSocketWaiter)(nil)
+
+func NewWebSocketWaiter() Waiter {
+	return &webSocketWaiter{
+		tlsConfig:  nil,
+		expect:     nil,
+		requestUrl: url.URL{},
+		host:       "",
+		send:       "",
+		attempts:   0,
+		retryDelay: config.RetryDelayDefault,
+		ticker:     time.NewTicker(config.RetryDelayDefault),
+	}
+}
+
+func (ww *webSocketWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
+	log := logger.Function("Wait").
+		Field("waiter", "WebSocketWaiter")
+	err := ww.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
+	success := false
+	startTime := time.Now()
+	log.Field("retryDelay", retryDelay.String()).
+		Info("Using retry delay")
+	ww.ticker = time.NewTicker(retryDelay)
+	ww.retryDelay = retryDelay
+	urlStr := utils.SanitizedURLString(url)
+	ww.attempts = 0
+	for ww.attempts < retryLimit {
+		log.Field("url", urlStr).
+			Infof("[%d/%d] Connecting", ww.attempts+1, retryLimit)
+		err = ww.connectOnce()
+		ww.attempts++ // no matter what happens, we made an attempt
+		if err != nil {
+			if ww.attempts >= retryLimit {
+				log.Err(err).
+					Error("Connect error: retry limit reached; giving up")
+				break
+			}
+			log.Err(err).
+				Error("Connect error; delaying until next retry")
+			ww.delayOnce()
+			continue
+		}
+		// we're good
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    ww.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Info("Successfully connected")
+		success = true
+		break
+	}
+	if !success {
+		errStr := fmt.Sprintf("Unable to connect to '%s' after %d attempts; elapsed time: %s", urlStr, ww.attempts, time.Since(startTime).String())
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    ww.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Error("Unable to connect")
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// probe makes a single attempt without retrying or delaying
+func (ww *webSocketWaiter) probe(url url.URL) error {
+	err := ww.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return ww.connectOnce()
+}
+
+// parseOptions builds the url of the upgrade request and reads the gowait-specific query parameters from the url
+func (ww *webSocketWaiter) parseOptions(wsUrl url.URL) error {
+	query := wsUrl.Query()
+	ww.send = query.Get(WebSocketParamSend)
+	ww.expect = nil
+	rawExpect := query.Get(WebSocketParamExpect)
+	if rawExpect != "" {
+		expect, err := regexp.Compile(rawExpect)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidOptions, WebSocketParamExpect, err.Error())
+		}
+		ww.expect = expect
+	}
+	port := webSocketPort
+	ww.tlsConfig = nil
+	if wsUrl.Scheme == "wss" {
+		port = webSocketSecurePort
+		tlsConfig, err := newTLSConfig(query)
+		if err != nil {
+			return err
+		}
+		tlsConfig.ServerName = wsUrl.Hostname()
+		ww.tlsConfig = tlsConfig
+	}
+	ww.host = wsUrl.Host
+	if wsUrl.Port() == "" {
+		ww.host = net.JoinHostPort(wsUrl.Hostname(), port)
+	}
+	// the upgrade request carries the rest of the query string to the server
+	for _, param := range webSocketParams {
+		query.Del(param)
+	}
+	wsUrl.RawQuery = query.Encode()
+	ww.requestUrl = wsUrl
+	return nil
+}
+
+// connectOnce performs the upgrade handshake, optionally exchanges a message, and closes the websocket
+func (ww *webSocketWaiter) connectOnce() error {
+	log := logger.Function("connectOnce").
+		Field("waiter", "WebSocketWaiter").
+		Field("host", ww.host)
+	dialer := &net.Dialer{Timeout: protocolTimeout}
+	var conn net.Conn
+	var err error
+	if ww.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", ww.host, ww.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", ww.host)
+	}
+	if err != nil {
+		log.Err(err).
+			Error("unable to connect to websocket server")
+		return err
+	}
+	defer func() {
+		err = conn.Close()
+		if err != nil {
+			log.Err(err).
+				Error("error closing websocket connection")
+		}
+	}()
+	err = conn.SetDeadline(time.Now().Add(protocolTimeout))
+	if err != nil {
+		log.Err(err).
+			Error("error setting connection deadline")
+		return err
+	}
+	reader := bufio.NewReader(conn)
+	err = ww.handshake(conn, reader)
+	if err != nil {
+		return err
+	}
+	if ww.send != "" {
+		err = webSocketWriteFrame(conn, webSocketOpText, []byte(ww.send))
+		if err != nil {
+			log.Err(err).
+				Error("error sending message")
+			return err
+		}
+	}
+	if ww.send != "" || ww.expect != nil {
+		err = ww.readReply(conn, reader)
+		if err != nil {
+			return err
+		}
+	}
+	closePayload := make([]byte, 2)
+	binary.BigEndian.PutUint16(closePayload, webSocketCloseNormal)
+	err = webSocketWriteFrame(conn, webSocketOpClose, closePayload)
+	if err != nil {
+		log.Err(err).
+			Error("error sending close frame")
+	}
+	return nil
+}
+
+// handshake sends the upgrade request and validates the server's response
+func (ww *webSocketWaiter) handshake(conn net.Conn, reader *bufio.Reader) error {
+	log := logger.Function("handshake").
+		Field("waiter", "WebSocketWaiter")
+	rawKey := make([]byte, 16)
+	_, err := rand.Read(rawKey)
+	if err != nil {
+		log.Err(err).
+			Error("error generating handshake key")
+		return err
+	}
+	key := base64.StdEncoding.EncodeToString(rawKey)
+	requestUrl := ww.requestUrl
+	requestUrl.User = nil
+	req, err := http.NewRequest(http.MethodGet, requestUrl.String(), nil)
+	if err != nil {
+		log.Err(err).
+			Error("error creating upgrade request")
+		return err
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", webSocketVersion)
+	if ww.requestUrl.User != nil {
+		password, _ := ww.requestUrl.User.Password()
+		req.SetBasicAuth(ww.requestUrl.User.Username(), password)
+	}
+	err = req.Write(conn)
+	if err != nil {
+		log.Err(err).
+			Error("error sending upgrade request")
+		return err
+	}
+	res, err := http.ReadResponse(reader, req)
+	if err != nil {
+		log.Err(err).
+			Error("error reading upgrade response")
+		return err
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		log.Errorf("upgrade error; code: %d, status: %s", res.StatusCode, res.Status)
+		return ErrWebSocketUpgrade
+	}
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	expectedAccept := base64.StdEncoding.EncodeToString(sum[:])
+	if !strings.EqualFold(res.Header.Get("Upgrade"), "websocket") || res.Header.Get("Sec-WebSocket-Accept") != expectedAccept {
+		log.Fields(map[string]interface{}{
+			"upgrade": res.Header.Get("Upgrade"),
+			"accept":  res.Header.Get("Sec-WebSocket-Accept"),
+		}).
+			Error("invalid upgrade response")
+		return ErrWebSocketUpgrade
+	}
+	log.Info("connection upgraded")
+	return nil
+}
+
+// readReply reads messages until one matches the expected pattern, or until the first message if there is no
+// pattern; pings are answered and other control frames are skipped
+func (ww *webSocketWaiter) readReply(conn net.Conn, reader *bufio.Reader) error {
+	log := logger.Function("readReply").
+		Field("waiter", "WebSocketWaiter")
+	message := make([]byte, 0)
+	for {
+		fin, opcode, payload, err := webSocketReadFrame(reader)
+		if err != nil {
+			log.Err(err).
+				Error("error reading frame")
+			return err
+		}
+		switch opcode {
+		case webSocketOpClose:
+			log.Error("server closed the websocket")
+			return ErrWebSocketClosed
+		case webSocketOpPing:
+			err = webSocketWriteFrame(conn, webSocketOpPong, payload)
+			if err != nil {
+				log.Err(err).
+					Error("error answering ping")
+				return err
+			}
+			continue
+		case webSocketOpPong:
+			continue
+		case webSocketOpText, webSocketOpBinary, webSocketOpContinuation:
+			message = append(message, payload...)
+		default:
+			log.Field("opcode", opcode).
+				Error("unknown frame opcode")
+			return ErrWebSocketFrame
+		}
+		if len(message) > webSocketMaxMessage {
+			return ErrWebSocketTooLarge
+		}
+		if !fin {
+			continue
+		}
+		if ww.expect == nil || ww.expect.Match(message) {
+			log.Field("bytes", len(message)).
+				Info("received reply")
+			return nil
+		}
+		log.Fields(map[string]interface{}{
+			"reply":  fmt.Sprintf("%q", message),
+			"expect": ww.expect.String(),
+		}).
+			Warn("reply did not match the expected pattern; waiting for another")
+		message = message[:0]
+	}
+}
+
+func (ww *webSocketWaiter) delayOnce() {
+	log := logger.Function("delayOnce").
+		Field("waiter", "WebSocketWaiter")
+	log.Field("delay", ww.retryDelay.String()).
+		Info("delaying until next attempt")
+	<-ww.ticker.C
+}
+
+// webSocketWriteFrame writes a single masked frame, as clients must (RFC 6455 section 5.3)
+func webSocketWriteFrame(w io.Writer, opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, 0x80|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, 0x80|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(len(payload)))
+	default:
+		frame = append(frame, 0x80|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(len(payload)))
+	}
+	mask := make([]byte, 4)
+	_, err := rand.Read(mask)
+	if err != nil {
+		return err
+	}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err = w.Write(frame)
+	return err
+}
+
+// webSocketReadFrame reads a single frame and returns its FIN flag, opcode and unmasked payload
+func webSocketReadFrame(r io.Reader) (bool, byte, []byte, error) {
+	header := make([]byte, 2)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return false, 0, nil, err
+	}
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		_, err = io.ReadFull(r, ext)
+		if err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		_, err = io.ReadFull(r, ext)
+		if err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext)
+	}
+	if length > webSocketMaxMessage {
+		return false, 0, nil, ErrWebSocketTooLarge
+	}
+	mask := make([]byte, 4)
+	if masked {
+		_, err = io.ReadFull(r, mask)
+		if err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload := make([]byte, length)
+	_, err = io.ReadFull(r, payload)
+	if err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
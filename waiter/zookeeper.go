@@ -0,0 +1,245 @@
+package waiter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/neflyte/gowait/config"
+	"github.com/neflyte/gowait/lib/logger"
+	"github.com/neflyte/gowait/lib/utils"
+)
+
+// url: zk://host:2181/?modes=leader,follower
+
+const (
+	ZooKeeperParamModes = "modes"
+
+	ZooKeeperModeLeader     = "leader"
+	ZooKeeperModeFollower   = "follower"
+	ZooKeeperModeStandalone = "standalone"
+	ZooKeeperModeObserver   = "observer"
+
+	zooKeeperPort = "2181"
+
+	zooKeeperRuok           = "ruok"
+	zooKeeperImok           = "imok"
+	zooKeeperSrvr           = "srvr"
+	zooKeeperModePrefix     = "Mode: "
+	zooKeeperNotWhitelisted = "not in the whitelist"
+	// zooKeeperMaxReply is the largest four letter word reply that is read
+	zooKeeperMaxReply = 64 * 1024
+)
+
+var (
+	ErrZooKeeperNotOk = errors.New("server did not answer ruok with imok")
+	ErrZooKeeperMode  = errors.New("server is not in a required mode")
+)
+
+type zooKeeperWaiter struct {
+	ticker     *time.Ticker
+	host       string
+	modes      []string
+	attempts   int
+	retryDelay time.Duration
+}
+
+var _ prober = (*zooKeeperWaiter)(nil)
+
+func NewZooKeeperWaiter() Waiter {
+	return &zooKeeperWaiter{
+		host:       "",
+		modes:      []string{ZooKeeperModeLeader, ZooKeeperModeFollower},
+		attempts:   0,
+		retryDelay: config.RetryDelayDefault,
+		ticker:     time.NewTicker(config.RetryDelayDefault),
+	}
+}
+
+func (zw *zooKeeperWaiter) Wait(url url.URL, retryDelay time.Duration, retryLimit int) error {
+	log := logger.Function("Wait").
+		Field("waiter", "ZooKeeperWaiter")
+	err := zw.parseOptions(url)
+	if err != nil {
+		log.Err(err).
+			Error("unable to parse waiter options from url")
+		return err
+	}
+	success := false
+	startTime := time.Now()
+	log.Field("retryDelay", retryDelay.String()).
+		Info("Using retry delay")
+	zw.ticker = time.NewTicker(retryDelay)
+	zw.retryDelay = retryDelay
+	urlStr := utils.SanitizedURLString(url)
+	zw.attempts = 0
+	for zw.attempts < retryLimit {
+		log.Field("url", urlStr).
+			Infof("[%d/%d] Connecting", zw.attempts+1, retryLimit)
+		err = zw.connectOnce()
+		zw.attempts++ // no matter what happens, we made an attempt
+		if err != nil {
+			if zw.attempts >= retryLimit {
+				log.Err(err).
+					Error("Connect error: retry limit reached; giving up")
+				break
+			}
+			log.Err(err).
+				Error("Connect error; delaying until next retry")
+			zw.delayOnce()
+			continue
+		}
+		// we're good
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    zw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Info("Successfully connected")
+		success = true
+		break
+	}
+	if !success {
+		errStr := fmt.Sprintf("Unable to connect to '%s' after %d attempts; elapsed time: %s", urlStr, zw.attempts, time.Since(startTime).String())
+		log.Fields(map[string]interface{}{
+			"url":         urlStr,
+			"attempts":    zw.attempts,
+			"retryLimit":  retryLimit,
+			"elapsedTime": time.Since(startTime).String(),
+		}).
+			Error("Unable to connect")
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// probe makes a single attempt without retrying or delaying
+func (zw *zooKeeperWaiter) probe(url url.URL) error {
+	err := zw.parseOptions(url)
+	if err != nil {
+		return err
+	}
+	return zw.connectOnce()
+}
+
+// parseOptions reads the server address and the gowait-specific query parameters from the url
+func (zw *zooKeeperWaiter) parseOptions(zkUrl url.URL) error {
+	zw.host = zkUrl.Host
+	if zkUrl.Port() == "" {
+		zw.host = net.JoinHostPort(zkUrl.Hostname(), zooKeeperPort)
+	}
+	zw.modes = []string{ZooKeeperModeLeader, ZooKeeperModeFollower}
+	modes := utils.SplitList(zkUrl.Query().Get(ZooKeeperParamModes))
+	for _, mode := range modes {
+		switch mode {
+		case ZooKeeperModeLeader, ZooKeeperModeFollower, ZooKeeperModeStandalone, ZooKeeperModeObserver:
+		default:
+			return fmt.Errorf("%w: unknown %s '%s'", ErrInvalidOptions, ZooKeeperParamModes, mode)
+		}
+	}
+	if len(modes) > 0 {
+		zw.modes = modes
+	}
+	return nil
+}
+
+func (zw *zooKeeperWaiter) connectOnce() error {
+	log := logger.Function("connectOnce").
+		Field("waiter", "ZooKeeperWaiter").
+		Field("host", zw.host)
+	// the server must be running without errors...
+	reply, err := zw.fourLetterWord(zooKeeperRuok)
+	if err != nil {
+		log.Err(err).
+			Error("error sending ruok")
+		return err
+	}
+	switch {
+	case reply == zooKeeperImok:
+	case strings.Contains(reply, zooKeeperNotWhitelisted):
+		log.Warn("ruok is not whitelisted on the server; relying on srvr")
+	default:
+		log.Field("reply", reply).
+			Error("server did not answer ruok with imok")
+		return ErrZooKeeperNotOk
+	}
+	// ...and be part of a quorum
+	reply, err = zw.fourLetterWord(zooKeeperSrvr)
+	if err != nil {
+		log.Err(err).
+			Error("error sending srvr")
+		return err
+	}
+	mode := ""
+	for _, line := range strings.Split(reply, "\n") {
+		if strings.HasPrefix(line, zooKeeperModePrefix) {
+			mode = strings.TrimSpace(strings.TrimPrefix(line, zooKeeperModePrefix))
+		}
+	}
+	for _, allowed := range zw.modes {
+		if mode == allowed {
+			log.Field("mode", mode).
+				Info("server is in a required mode")
+			return nil
+		}
+	}
+	log.Fields(map[string]interface{}{
+		"mode":  mode,
+		"modes": strings.Join(zw.modes, ","),
+	}).
+		Error("server is not in a required mode")
+	return ErrZooKeeperMode
+}
+
+// fourLetterWord sends a four letter word command on a new connection and returns the reply; the server closes
+// the connection once it has replied
+func (zw *zooKeeperWaiter) fourLetterWord(command string) (string, error) {
+	log := logger.Function("fourLetterWord").
+		Field("waiter", "ZooKeeperWaiter").
+		Field("command", command)
+	conn, err := net.DialTimeout("tcp", zw.host, protocolTimeout)
+	if err != nil {
+		log.Err(err).
+			Error("unable to connect to tcp address")
+		return "", err
+	}
+	defer func() {
+		err = conn.Close()
+		if err != nil {
+			log.Err(err).
+				Error("error closing tcp connection")
+		}
+	}()
+	err = conn.SetDeadline(time.Now().Add(protocolTimeout))
+	if err != nil {
+		log.Err(err).
+			Error("error setting connection deadline")
+		return "", err
+	}
+	_, err = conn.Write([]byte(command))
+	if err != nil {
+		log.Err(err).
+			Error("error sending command")
+		return "", err
+	}
+	reply, err := io.ReadAll(io.LimitReader(conn, zooKeeperMaxReply))
+	if err != nil {
+		log.Err(err).
+			Error("error reading reply")
+		return "", err
+	}
+	return strings.TrimSpace(string(reply)), nil
+}
+
+func (zw *zooKeeperWaiter) delayOnce() {
+	log := logger.Function("delayOnce").
+		Field("waiter", "ZooKeeperWaiter")
+	log.Field("delay", zw.retryDelay.String()).
+		Info("delaying until next attempt")
+	<-zw.ticker.C
+}